@@ -1,6 +1,12 @@
 package concurrency
 
-import "sync"
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // SafeCounter protects an integer with a mutex to avoid data races.
 type SafeCounter struct {
@@ -21,3 +27,239 @@ func (c *SafeCounter) Value() int {
 	defer c.mu.Unlock()
 	return c.n
 }
+
+// AtomicCounter is a lock-free counter for moderately shared values. Every
+// Inc still hits the same cache line, so under heavy parallel writes prefer
+// ShardedCounter.
+type AtomicCounter struct {
+	n atomic.Int64
+}
+
+// Inc adds one.
+func (c *AtomicCounter) Inc() { c.n.Add(1) }
+
+// Add adds d, which may be negative.
+func (c *AtomicCounter) Add(d int64) { c.n.Add(d) }
+
+// Snapshot returns the current count.
+func (c *AtomicCounter) Snapshot() int64 { return c.n.Load() }
+
+// Reset sets the count to zero and returns the value it replaced, so an
+// interval reporter loses no increments between reading and clearing.
+func (c *AtomicCounter) Reset() int64 { return c.n.Swap(0) }
+
+// shard pads each slot to its own cache line so writers on different CPUs
+// do not invalidate each other.
+type shard struct {
+	n atomic.Int64
+	_ [56]byte
+}
+
+// ShardedCounter spreads increments over several cache-line-sized shards and
+// sums them on read. Writes scale with cores; reads cost one load per shard,
+// so use it for hot paths that are written far more often than read.
+type ShardedCounter struct {
+	shards []shard
+	mask   uint32
+}
+
+// NewShardedCounter returns a counter with shards rounded up to a power of
+// two; zero means one per GOMAXPROCS.
+func NewShardedCounter(shards int) *ShardedCounter {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	return &ShardedCounter{shards: make([]shard, n), mask: uint32(n - 1)}
+}
+
+// Inc adds one.
+func (c *ShardedCounter) Inc() { c.Add(1) }
+
+// Add adds d to a randomly chosen shard. The runtime's per-thread generator
+// keeps the choice cheap and spreads concurrent writers.
+func (c *ShardedCounter) Add(d int64) {
+	c.shards[rand.Uint32()&c.mask].n.Add(d)
+}
+
+// Snapshot sums the shards. Concurrent Adds may or may not be included.
+func (c *ShardedCounter) Snapshot() int64 {
+	var sum int64
+	for i := range c.shards {
+		sum += c.shards[i].n.Load()
+	}
+	return sum
+}
+
+// Reset clears every shard and returns the total it removed. Each shard is
+// swapped atomically, so no increment is lost or counted twice.
+func (c *ShardedCounter) Reset() int64 {
+	var sum int64
+	for i := range c.shards {
+		sum += c.shards[i].n.Swap(0)
+	}
+	return sum
+}
+
+// LabeledCounter keeps one counter per label, such as per route. Existing
+// labels are updated under a read lock, so only the first use of a label
+// takes the write lock.
+type LabeledCounter struct {
+	mu       sync.RWMutex
+	counters map[string]*AtomicCounter
+}
+
+// Inc adds one to label.
+func (c *LabeledCounter) Inc(label string) { c.Add(label, 1) }
+
+// Add adds d to label, creating it on first use.
+func (c *LabeledCounter) Add(label string, d int64) {
+	c.mu.RLock()
+	if ctr, ok := c.counters[label]; ok {
+		ctr.Add(d)
+		c.mu.RUnlock()
+		return
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counters == nil {
+		c.counters = make(map[string]*AtomicCounter)
+	}
+	ctr, ok := c.counters[label]
+	if !ok {
+		ctr = &AtomicCounter{}
+		c.counters[label] = ctr
+	}
+	ctr.Add(d)
+}
+
+// Value returns label's count, zero if it was never used.
+func (c *LabeledCounter) Value(label string) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if ctr, ok := c.counters[label]; ok {
+		return ctr.Snapshot()
+	}
+	return 0
+}
+
+// Snapshot copies every label's count.
+func (c *LabeledCounter) Snapshot() map[string]int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]int64, len(c.counters))
+	for label, ctr := range c.counters {
+		out[label] = ctr.Snapshot()
+	}
+	return out
+}
+
+// Reset drops all labels and returns their final counts. It holds the write
+// lock, so the result is consistent across labels.
+func (c *LabeledCounter) Reset() map[string]int64 {
+	c.mu.Lock()
+	old := c.counters
+	c.counters = nil
+	c.mu.Unlock()
+	out := make(map[string]int64, len(old))
+	for label, ctr := range old {
+		out[label] = ctr.Snapshot()
+	}
+	return out
+}
+
+// RateCounterOptions configures a RateCounter.
+type RateCounterOptions struct {
+	// Window is how far back events count; zero means one minute.
+	Window time.Duration
+	// Buckets splits the window; more buckets make the window edge sharper.
+	// Zero means 60.
+	Buckets int
+}
+
+// RateCounter counts events over a sliding window, for example requests in
+// the last minute. The window moves in bucket-sized steps, so the count
+// may include up to one bucket of older events.
+type RateCounter struct {
+	mu      sync.Mutex
+	width   time.Duration
+	buckets []rateBucket
+	now     func() time.Time
+}
+
+type rateBucket struct {
+	slot int64 // which bucket-width interval since the epoch the count is for
+	n    int64
+}
+
+// RateSnapshot is a RateCounter reading.
+type RateSnapshot struct {
+	Count     int64
+	Window    time.Duration
+	PerSecond float64
+}
+
+// NewRateCounter returns an empty RateCounter.
+func NewRateCounter(opts RateCounterOptions) *RateCounter {
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Buckets <= 0 {
+		opts.Buckets = 60
+	}
+	return &RateCounter{
+		width:   max(opts.Window/time.Duration(opts.Buckets), 1),
+		buckets: make([]rateBucket, opts.Buckets),
+		now:     time.Now,
+	}
+}
+
+// Inc records one event.
+func (c *RateCounter) Inc() { c.Add(1) }
+
+// Add records n events now.
+func (c *RateCounter) Add(n int64) {
+	slot := c.now().UnixNano() / int64(c.width)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &c.buckets[slot%int64(len(c.buckets))]
+	if b.slot != slot {
+		b.slot, b.n = slot, 0
+	}
+	b.n += n
+}
+
+// Snapshot returns the events in the current window and their rate.
+func (c *RateCounter) Snapshot() RateSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.read()
+}
+
+// Reset forgets all events and returns the final Snapshot.
+func (c *RateCounter) Reset() RateSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.read()
+	clear(c.buckets)
+	return s
+}
+
+// read sums the buckets still inside the window; c.mu must be held.
+func (c *RateCounter) read() RateSnapshot {
+	slot := c.now().UnixNano() / int64(c.width)
+	oldest := slot - int64(len(c.buckets)) + 1
+	s := RateSnapshot{Window: c.width * time.Duration(len(c.buckets))}
+	for _, b := range c.buckets {
+		if b.slot >= oldest && b.slot <= slot {
+			s.Count += b.n
+		}
+	}
+	s.PerSecond = float64(s.Count) / s.Window.Seconds()
+	return s
+}
@@ -1,8 +1,10 @@
 package concurrency
 
 import (
+	"reflect"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestSafeCounter(t *testing.T) {
@@ -23,3 +25,168 @@ func TestSafeCounter(t *testing.T) {
 		t.Fatalf("counter = %d, want %d", got, total)
 	}
 }
+
+// hammer runs inc from many goroutines and returns the expected total.
+func hammer(inc func()) int64 {
+	const goroutines, each = 50, 200
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < each; j++ {
+				inc()
+			}
+		}()
+	}
+	wg.Wait()
+	return goroutines * each
+}
+
+func TestCounters(t *testing.T) {
+	atomicC := &AtomicCounter{}
+	sharded := NewShardedCounter(0)
+	labeled := &LabeledCounter{}
+	cases := []struct {
+		name     string
+		inc      func()
+		snapshot func() int64
+		reset    func() int64
+	}{
+		{"atomic", atomicC.Inc, atomicC.Snapshot, atomicC.Reset},
+		{"sharded", sharded.Inc, sharded.Snapshot, sharded.Reset},
+		{"labeled", func() { labeled.Inc("/v1/chat") }, func() int64 { return labeled.Value("/v1/chat") },
+			func() int64 { return labeled.Reset()["/v1/chat"] }},
+	}
+	for _, tc := range cases {
+		want := hammer(tc.inc)
+		if got := tc.snapshot(); got != want {
+			t.Fatalf("%s: Snapshot()=%d want %d", tc.name, got, want)
+		}
+		if got := tc.reset(); got != want {
+			t.Fatalf("%s: Reset()=%d want %d", tc.name, got, want)
+		}
+		if got := tc.snapshot(); got != 0 {
+			t.Fatalf("%s: Snapshot()=%d after Reset", tc.name, got)
+		}
+	}
+}
+
+func TestShardedCounterResetLosesNothing(t *testing.T) {
+	c := NewShardedCounter(4)
+	var total int64
+	var mu sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			mu.Lock()
+			total += c.Reset()
+			mu.Unlock()
+		}
+	}()
+	want := hammer(c.Inc)
+	<-done
+	if got := total + c.Reset(); got != want {
+		t.Fatalf("increments seen by Reset=%d want %d", got, want)
+	}
+}
+
+func TestLabeledCounterSnapshot(t *testing.T) {
+	var c LabeledCounter
+	c.Inc("GET /a")
+	c.Add("GET /a", 2)
+	c.Inc("POST /b")
+	want := map[string]int64{"GET /a": 3, "POST /b": 1}
+	if got := c.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Snapshot()=%v want %v", got, want)
+	}
+	if got := c.Reset(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Reset()=%v want %v", got, want)
+	}
+	if got := c.Snapshot(); len(got) != 0 || c.Value("GET /a") != 0 {
+		t.Fatalf("labels survived Reset: %v", got)
+	}
+}
+
+func TestRateCounter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewRateCounter(RateCounterOptions{Window: 10 * time.Second, Buckets: 10})
+	c.now = func() time.Time { return now }
+
+	c.Add(5)
+	now = now.Add(3 * time.Second)
+	c.Add(15)
+	if s := c.Snapshot(); s.Count != 20 || s.Window != 10*time.Second || s.PerSecond != 2 {
+		t.Fatalf("snapshot=%+v want 20 events, 2/s", s)
+	}
+
+	// The first events slide out of the window, the later ones stay.
+	now = now.Add(8 * time.Second)
+	if s := c.Snapshot(); s.Count != 15 {
+		t.Fatalf("count=%d want 15 after the first bucket expired", s.Count)
+	}
+	now = now.Add(time.Minute)
+	if s := c.Snapshot(); s.Count != 0 {
+		t.Fatalf("count=%d want 0 after the window passed", s.Count)
+	}
+
+	c.Add(7)
+	if s := c.Reset(); s.Count != 7 {
+		t.Fatalf("Reset()=%+v want 7", s)
+	}
+	if s := c.Snapshot(); s.Count != 0 {
+		t.Fatalf("count=%d after Reset", s.Count)
+	}
+}
+
+// The benchmarks compare the counters under parallel writes, e.g.
+//
+//	go test -bench=Counter -cpu=1,8
+func BenchmarkSafeCounter(b *testing.B) {
+	c := &SafeCounter{}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc()
+		}
+	})
+}
+
+func BenchmarkAtomicCounter(b *testing.B) {
+	c := &AtomicCounter{}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc()
+		}
+	})
+}
+
+func BenchmarkShardedCounter(b *testing.B) {
+	c := NewShardedCounter(0)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc()
+		}
+	})
+}
+
+func BenchmarkLabeledCounter(b *testing.B) {
+	c := &LabeledCounter{}
+	labels := []string{"GET /a", "GET /b", "POST /c", "GET /d"}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Inc(labels[i%len(labels)])
+			i++
+		}
+	})
+}
+
+func BenchmarkRateCounter(b *testing.B) {
+	c := NewRateCounter(RateCounterOptions{})
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc()
+		}
+	})
+}
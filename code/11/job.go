@@ -0,0 +1,77 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError is a recovered job panic. Stack is the panicking goroutine's
+// stack trace.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("job panicked: %v", e.Value) }
+
+// Unwrap returns the panic value if it was an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// SlowJob describes a job that overran JobOptions.SlowAfter.
+type SlowJob struct {
+	Input   any
+	Started time.Time
+	Elapsed time.Duration
+}
+
+// JobOptions supervises individual jobs run by Pool, WorkerPool and RunJob.
+type JobOptions struct {
+	// Timeout gives each job its own deadline derived from the pool context;
+	// zero means none. Jobs must watch ctx for it to take effect.
+	Timeout time.Duration
+	// SlowAfter arms a watchdog that reports jobs still running after it;
+	// zero disables it.
+	SlowAfter time.Duration
+	// OnSlow receives watchdog reports, from the watchdog's goroutine while
+	// the job is still running. Nil logs a warning with slog.
+	OnSlow func(SlowJob)
+}
+
+// RunJob calls fn(ctx, in) under opts: the job gets a deadline if Timeout is
+// set, a panic comes back as a *PanicError instead of crashing the process,
+// and overrunning SlowAfter is reported once to OnSlow.
+func RunJob[In, Out any](ctx context.Context, opts JobOptions, fn func(context.Context, In) (Out, error), in In) (out Out, err error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if opts.SlowAfter > 0 {
+		started := time.Now()
+		watchdog := time.AfterFunc(opts.SlowAfter, func() {
+			opts.reportSlow(SlowJob{Input: in, Started: started, Elapsed: time.Since(started)})
+		})
+		defer watchdog.Stop()
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			var zero Out
+			out, err = zero, &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx, in)
+}
+
+func (o JobOptions) reportSlow(job SlowJob) {
+	if o.OnSlow != nil {
+		o.OnSlow(job)
+		return
+	}
+	slog.Warn("job overran", "input", job.Input, "started", job.Started, "elapsed", job.Elapsed)
+}
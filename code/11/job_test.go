@@ -0,0 +1,118 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunJobRecoversPanic(t *testing.T) {
+	boom := func(ctx context.Context, n int) (int, error) {
+		var m map[string]int
+		m["x"] = n // nil map write panics
+		return n, nil
+	}
+	_, err := RunJob(context.Background(), JobOptions{}, boom, 1)
+	var perr *PanicError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err=%v want *PanicError", err)
+	}
+	if !strings.Contains(string(perr.Stack), "TestRunJobRecoversPanic") {
+		t.Fatalf("stack does not show the panicking job:\n%s", perr.Stack)
+	}
+	if perr.Unwrap() == nil {
+		t.Fatalf("runtime error panic should unwrap to the error value")
+	}
+
+	_, err = RunJob(context.Background(), JobOptions{}, func(context.Context, int) (int, error) { panic(io.EOF) }, 1)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("err=%v should unwrap to io.EOF", err)
+	}
+}
+
+func TestRunJobTimeout(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wait := func(ctx context.Context, _ int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	start := time.Now()
+	_, err := RunJob(parent, JobOptions{Timeout: 10 * time.Millisecond}, wait, 1)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Fatalf("err=%v after %v", err, time.Since(start))
+	}
+	if parent.Err() != nil {
+		t.Fatalf("job deadline leaked into the parent context")
+	}
+}
+
+func TestRunJobWatchdog(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		slow []SlowJob
+	)
+	opts := JobOptions{SlowAfter: 20 * time.Millisecond, OnSlow: func(j SlowJob) {
+		mu.Lock()
+		slow = append(slow, j)
+		mu.Unlock()
+	}}
+	sleep := func(_ context.Context, d time.Duration) (struct{}, error) {
+		time.Sleep(d)
+		return struct{}{}, nil
+	}
+	_, _ = RunJob(context.Background(), opts, sleep, 0)
+	_, _ = RunJob(context.Background(), opts, sleep, 60*time.Millisecond)
+	time.Sleep(40 * time.Millisecond) // a fast job's watchdog must stay silent
+	mu.Lock()
+	defer mu.Unlock()
+	if len(slow) != 1 || slow[0].Input != 60*time.Millisecond || slow[0].Elapsed < 20*time.Millisecond {
+		t.Fatalf("reports=%+v want one for the 60ms job", slow)
+	}
+}
+
+func TestPoolIsolatesPanics(t *testing.T) {
+	fn := func(ctx context.Context, n int) (int, error) {
+		if n == 2 {
+			panic("bad item")
+		}
+		return n, nil
+	}
+	results, err := NewPool(fn, PoolOptions{Workers: 2}).Run(context.Background(), []int{0, 1, 2, 3})
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "bad item" {
+		t.Fatalf("err=%v want the panic as an item error", err)
+	}
+	for i, r := range results {
+		if i != 2 && (r.Err != nil || r.Value != i) {
+			t.Fatalf("results[%d]=%+v", i, r)
+		}
+	}
+}
+
+func TestWorkerPoolIsolatesPanics(t *testing.T) {
+	fn := func(ctx context.Context, n int) (int, error) {
+		if n < 0 {
+			panic("negative")
+		}
+		return n, nil
+	}
+	p := NewWorkerPool(fn, WorkerPoolOptions{Workers: 1, QueueSize: 2})
+	defer p.Close()
+	bad, _ := p.Submit(context.Background(), -1)
+	good, _ := p.Submit(context.Background(), 7)
+	var perr *PanicError
+	if _, err := bad.Wait(context.Background()); !errors.As(err, &perr) {
+		t.Fatalf("err=%v want *PanicError", err)
+	}
+	if v, err := good.Wait(context.Background()); err != nil || v != 7 {
+		t.Fatalf("worker did not survive the panic: %d, %v", v, err)
+	}
+	if st := p.Stats(); st.Failed != 1 {
+		t.Fatalf("stats=%+v want one failure", st)
+	}
+}
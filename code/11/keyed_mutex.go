@@ -0,0 +1,96 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyedMutex serializes work per key, such as per conversation ID, without
+// one global lock: holders of different keys never wait for each other.
+// A key's entry exists only while someone holds or waits for it, so idle
+// keys are removed on the last Unlock and the map does not grow.
+type KeyedMutex[K comparable] struct {
+	mu   sync.Mutex
+	keys map[K]*keyLock
+}
+
+type keyLock struct {
+	ch   chan struct{} // holds a token while locked
+	refs int           // holders plus waiters; guarded by KeyedMutex.mu
+}
+
+func (m *KeyedMutex[K]) ref(key K) *keyLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.keys == nil {
+		m.keys = make(map[K]*keyLock)
+	}
+	l := m.keys[key]
+	if l == nil {
+		l = &keyLock{ch: make(chan struct{}, 1)}
+		m.keys[key] = l
+	}
+	l.refs++
+	return l
+}
+
+func (m *KeyedMutex[K]) unref(key K, l *keyLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(m.keys, key)
+	}
+}
+
+// Lock blocks until key is free and takes it.
+func (m *KeyedMutex[K]) Lock(key K) {
+	m.ref(key).ch <- struct{}{}
+}
+
+// LockContext is Lock that gives up when ctx is done, returning ctx.Err().
+func (m *KeyedMutex[K]) LockContext(ctx context.Context, key K) error {
+	l := m.ref(key)
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		m.unref(key, l)
+		return ctx.Err()
+	}
+}
+
+// TryLock takes key if it is free and reports whether it did.
+func (m *KeyedMutex[K]) TryLock(key K) bool {
+	l := m.ref(key)
+	select {
+	case l.ch <- struct{}{}:
+		return true
+	default:
+		m.unref(key, l)
+		return false
+	}
+}
+
+// Unlock releases key. Unlocking a key that is not locked panics.
+func (m *KeyedMutex[K]) Unlock(key K) {
+	m.mu.Lock()
+	l := m.keys[key]
+	m.mu.Unlock()
+	if l == nil {
+		panic("concurrency: unlock of unlocked key")
+	}
+	select {
+	case <-l.ch:
+	default:
+		panic("concurrency: unlock of unlocked key")
+	}
+	m.unref(key, l)
+}
+
+// Len reports how many keys are held or waited for.
+func (m *KeyedMutex[K]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.keys)
+}
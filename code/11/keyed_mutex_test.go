@@ -0,0 +1,88 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/go-class/11/leaktest"
+)
+
+func TestKeyedMutexSerializesPerKey(t *testing.T) {
+	leaktest.Check(t)
+	var m KeyedMutex[string]
+	counts := map[string]*int{"a": new(int), "b": new(int), "c": new(int)}
+	var wg sync.WaitGroup
+	for i := 0; i < 300; i++ {
+		key := []string{"a", "b", "c"}[i%3]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Lock(key)
+			defer m.Unlock(key)
+			*counts[key]++ // the race detector fails this if keys are not serialized
+		}()
+	}
+	wg.Wait()
+	for key, n := range counts {
+		if *n != 100 {
+			t.Fatalf("count[%s]=%d want 100", key, *n)
+		}
+	}
+	if n := m.Len(); n != 0 {
+		t.Fatalf("%d idle keys left in the map", n)
+	}
+}
+
+func TestKeyedMutexIndependentKeys(t *testing.T) {
+	var m KeyedMutex[int]
+	m.Lock(1)
+	if !m.TryLock(2) {
+		t.Fatalf("key 2 blocked by key 1")
+	}
+	if m.TryLock(1) {
+		t.Fatalf("TryLock took a held key")
+	}
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len()=%d want 2", n)
+	}
+	m.Unlock(1)
+	m.Unlock(2)
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len()=%d after unlocking everything", n)
+	}
+}
+
+func TestKeyedMutexLockContext(t *testing.T) {
+	leaktest.Check(t)
+	var m KeyedMutex[string]
+	m.Lock("conv")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.LockContext(ctx, "conv"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LockContext=%v want DeadlineExceeded", err)
+	}
+
+	got := make(chan error, 1)
+	go func() { got <- m.LockContext(context.Background(), "conv") }()
+	m.Unlock("conv")
+	if err := <-got; err != nil {
+		t.Fatal(err)
+	}
+	m.Unlock("conv")
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len()=%d, a cancelled waiter leaked its entry", n)
+	}
+}
+
+func TestKeyedMutexUnlockUnlocked(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Unlock of an unlocked key should panic")
+		}
+	}()
+	var m KeyedMutex[string]
+	m.Unlock("x")
+}
@@ -0,0 +1,79 @@
+// Package leaktest fails tests that leave goroutines behind. Call Check at the
+// start of a test; when the test ends it compares the running goroutines
+// against the snapshot taken then.
+package leaktest
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Timeout is how long Check waits for goroutines that are still shutting
+// down, such as workers draining after a Close.
+var Timeout = time.Second
+
+// Check snapshots the running goroutines and registers a cleanup that
+// reports, with their stacks, any goroutines started since and still
+// running after Timeout. Tests using it must not run in parallel.
+func Check(t testing.TB) {
+	t.Helper()
+	before := snapshot()
+	t.Cleanup(func() {
+		t.Helper()
+		var leaked []string
+		deadline := time.Now().Add(Timeout)
+		for {
+			leaked = leaked[:0]
+			for id, stack := range snapshot() {
+				if _, ok := before[id]; !ok && !ignored(stack) {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		sort.Strings(leaked)
+		for _, stack := range leaked {
+			t.Errorf("leaked goroutine:\n%s", stack)
+		}
+	})
+}
+
+// snapshot maps goroutine ids ("goroutine 7") to their stacks.
+func snapshot() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	goroutines := make(map[string]string)
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		header, _, _ := strings.Cut(g, " [")
+		goroutines[header] = g
+	}
+	return goroutines
+}
+
+// ignored reports goroutines owned by the runtime or the testing package
+// rather than by the code under test.
+func ignored(stack string) bool {
+	for _, s := range []string{
+		"created by testing.(*T).Run", // other tests
+		"os/signal.signal_recv",
+		"runtime.ensureSigM",
+	} {
+		if strings.Contains(stack, s) {
+			return true
+		}
+	}
+	return false
+}
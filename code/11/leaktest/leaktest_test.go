@@ -0,0 +1,53 @@
+package leaktest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder captures what Check reports instead of failing the real test.
+type recorder struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (r *recorder) Helper()                   {}
+func (r *recorder) Cleanup(f func())          { r.cleanups = append(r.cleanups, f) }
+func (r *recorder) Errorf(f string, a ...any) { r.errors = append(r.errors, fmt.Sprintf(f, a...)) }
+
+func (r *recorder) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestCheckReportsLeak(t *testing.T) {
+	defer func(old time.Duration) { Timeout = old }(Timeout)
+	Timeout = 50 * time.Millisecond
+
+	stop := make(chan struct{})
+	defer close(stop)
+	rec := &recorder{TB: t}
+	Check(rec)
+	go blockUntil(stop)
+	rec.finish()
+
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "leaktest.blockUntil") {
+		t.Fatalf("errors=%q want one leak in blockUntil", rec.errors)
+	}
+}
+
+func TestCheckWaitsForShutdown(t *testing.T) {
+	rec := &recorder{TB: t}
+	Check(rec)
+	go time.Sleep(30 * time.Millisecond) // exits within Timeout
+	rec.finish()
+	if len(rec.errors) != 0 {
+		t.Fatalf("unexpected leak reports: %q", rec.errors)
+	}
+}
+
+func blockUntil(stop chan struct{}) { <-stop }
@@ -0,0 +1,137 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrSkipped marks items a fail-fast Pool never ran because an earlier item
+// failed.
+var ErrSkipped = errors.New("skipped after an earlier failure")
+
+// Result is the outcome of one item; Results keep the order of the inputs.
+type Result[Out any] struct {
+	Value Out
+	Err   error
+}
+
+// ItemError reports which input failed.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string { return fmt.Sprintf("item %d: %v", e.Index, e.Err) }
+
+func (e *ItemError) Unwrap() error { return e.Err }
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	// Workers bounds the goroutines running fn; zero means GOMAXPROCS.
+	Workers int
+	// FailFast cancels the remaining items after the first error. By default
+	// every item runs and every error is collected.
+	FailFast bool
+	// JobOptions recovers panics as *PanicError and sets per-item deadlines
+	// and the slow-job watchdog.
+	JobOptions
+}
+
+// Pool runs fn over a slice of inputs with a bounded number of workers.
+type Pool[In, Out any] struct {
+	fn   func(context.Context, In) (Out, error)
+	opts PoolOptions
+}
+
+// NewPool returns a Pool that applies fn to each input.
+func NewPool[In, Out any](fn func(context.Context, In) (Out, error), opts PoolOptions) *Pool[In, Out] {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	return &Pool[In, Out]{fn: fn, opts: opts}
+}
+
+// Run applies fn to every input and returns one Result per input, in input
+// order. The error is ctx.Err() if ctx ended first; otherwise, in fail-fast
+// mode, the first *ItemError, with the items that never ran set to
+// ErrSkipped; otherwise all *ItemErrors joined, or nil.
+func (p *Pool[In, Out]) Run(ctx context.Context, inputs []In) ([]Result[Out], error) {
+	results := make([]Result[Out], len(inputs))
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	skipped := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return ErrSkipped
+	}
+	next := make(chan int)
+	worker := func() {
+		defer wg.Done()
+		for i := range next {
+			if runCtx.Err() != nil {
+				results[i].Err = skipped()
+				continue
+			}
+			out, err := RunJob(runCtx, p.opts.JobOptions, p.fn, inputs[i])
+			results[i] = Result[Out]{Value: out, Err: err}
+			if err != nil && p.opts.FailFast {
+				once.Do(func() {
+					first = &ItemError{Index: i, Err: err}
+					cancel()
+				})
+			}
+		}
+	}
+	workers := min(p.opts.Workers, len(inputs))
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go worker()
+	}
+
+feed:
+	for i := range inputs {
+		select {
+		case <-runCtx.Done():
+			// Items not handed out are only ever touched here.
+			for j := i; j < len(inputs); j++ {
+				results[j].Err = skipped()
+			}
+			break feed
+		case next <- i:
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	if p.opts.FailFast {
+		return results, first
+	}
+	var errs []error
+	for i, r := range results {
+		if r.Err != nil {
+			errs = append(errs, &ItemError{Index: i, Err: r.Err})
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+/*
+results 没有数据竞争的原因：
+
+- 每个下标只会被写一次：下标 i 要么通过 next 通道交给唯一一个 worker，要么在取消后由发送方直接标记为跳过，二者互斥。
+- Run 在 wg.Wait() 之后才读取 results；写入阶段只有 worker 和发送方各写各的下标，读取阶段只有调用方在读，读写不重叠。
+- fail-fast 的 first 只在 once.Do 中写入，同样在 wg.Wait() 之后才读取。
+*/
@@ -0,0 +1,137 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/go-class/11/leaktest"
+)
+
+func TestPoolPreservesOrder(t *testing.T) {
+	// Later items finish first, so completion order is the reverse of input order.
+	fn := func(ctx context.Context, n int) (string, error) {
+		time.Sleep(time.Duration(10-n) * time.Millisecond)
+		return strconv.Itoa(n * 10), nil
+	}
+	results, err := NewPool(fn, PoolOptions{Workers: 4}).Run(context.Background(), []int{0, 1, 2, 3, 4, 5, 6, 7})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, r := range results {
+		if want := strconv.Itoa(i * 10); r.Value != want || r.Err != nil {
+			t.Fatalf("results[%d]=%+v want %s", i, r, want)
+		}
+	}
+}
+
+func TestPoolBoundsWorkers(t *testing.T) {
+	var running, peak atomic.Int32
+	fn := func(ctx context.Context, n int) (int, error) {
+		cur := running.Add(1)
+		for {
+			old := peak.Load()
+			if cur <= old || peak.CompareAndSwap(old, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return n, nil
+	}
+	if _, err := NewPool(fn, PoolOptions{Workers: 3}).Run(context.Background(), make([]int, 20)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := peak.Load(); got != 3 {
+		t.Fatalf("peak concurrency=%d want 3", got)
+	}
+}
+
+var errOdd = errors.New("odd input")
+
+func failOdd(ctx context.Context, n int) (int, error) {
+	if n%2 == 1 {
+		return 0, errOdd
+	}
+	return n * n, nil
+}
+
+func TestPoolCollectAll(t *testing.T) {
+	leaktest.Check(t)
+	results, err := NewPool(failOdd, PoolOptions{Workers: 2}).Run(context.Background(), []int{0, 1, 2, 3, 4})
+	if !errors.Is(err, errOdd) {
+		t.Fatalf("err=%v want errOdd", err)
+	}
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 1 {
+		t.Fatalf("first item error=%v want index 1", itemErr)
+	}
+	for i, r := range results {
+		if wantErr := i%2 == 1; (r.Err != nil) != wantErr {
+			t.Fatalf("results[%d].Err=%v", i, r.Err)
+		}
+		if i%2 == 0 && r.Value != i*i {
+			t.Fatalf("results[%d]=%d want %d", i, r.Value, i*i)
+		}
+	}
+}
+
+func TestPoolFailFast(t *testing.T) {
+	leaktest.Check(t)
+	var ran atomic.Int32
+	fn := func(ctx context.Context, n int) (int, error) {
+		ran.Add(1)
+		if n == 0 {
+			return 0, errOdd
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Second):
+			return n, nil
+		}
+	}
+	inputs := make([]int, 50)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	start := time.Now()
+	results, err := NewPool(fn, PoolOptions{Workers: 2, FailFast: true}).Run(context.Background(), inputs)
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 0 || !errors.Is(err, errOdd) {
+		t.Fatalf("err=%v want item 0 errOdd", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("in-flight items were not canceled")
+	}
+	if ran.Load() > 3 {
+		t.Fatalf("ran %d items after failing fast", ran.Load())
+	}
+	if !errors.Is(results[len(results)-1].Err, ErrSkipped) {
+		t.Fatalf("last item err=%v want ErrSkipped", results[len(results)-1].Err)
+	}
+}
+
+func TestPoolParentCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := NewPool(failOdd, PoolOptions{}).Run(ctx, []int{0, 1, 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err=%v want context.Canceled", err)
+	}
+	for i, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Fatalf("results[%d].Err=%v want context.Canceled", i, r.Err)
+		}
+	}
+}
+
+func TestPoolEmpty(t *testing.T) {
+	results, err := NewPool(failOdd, PoolOptions{Workers: 4}).Run(context.Background(), nil)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("got %v, %v", results, err)
+	}
+}
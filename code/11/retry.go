@@ -0,0 +1,225 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backoff decides how long to wait before the next attempt. attempt is the
+// number of the attempt that just failed, starting at 1, and prev is the
+// previous wait (zero after the first failure).
+type Backoff interface {
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// ConstantBackoff waits the same time between attempts.
+type ConstantBackoff time.Duration
+
+func (c ConstantBackoff) Next(int, time.Duration) time.Duration { return time.Duration(c) }
+
+// ExponentialBackoff waits Base, Base*Multiplier, Base*Multiplier², … up to
+// Max. Multiplier defaults to 2 and a zero Max means no cap.
+type ExponentialBackoff struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+func (e ExponentialBackoff) Next(attempt int, _ time.Duration) time.Duration {
+	m := e.Multiplier
+	if m <= 0 {
+		m = 2
+	}
+	d := float64(e.Base) * math.Pow(m, float64(attempt-1))
+	if e.Max > 0 && d > float64(e.Max) {
+		return e.Max
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// DecorrelatedJitter waits a random time between Base and three times the
+// previous wait, capped at Max. The randomness spreads out clients that
+// failed together, so they do not retry in lockstep.
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (j DecorrelatedJitter) Next(_ int, prev time.Duration) time.Duration {
+	hi := max(prev*3, j.Base)
+	d := j.Base
+	if hi > j.Base {
+		d += rand.N(hi - j.Base)
+	}
+	if j.Max > 0 && d > j.Max {
+		return j.Max
+	}
+	return d
+}
+
+// PermanentError marks an error that retrying cannot fix.
+type PermanentError struct{ Err error }
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent wraps err so that Retry gives up on it immediately.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// RetryAfterError carries a server's Retry-After hint; Retry waits exactly
+// that long instead of its Backoff.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %v)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfter attaches a Retry-After hint to err.
+func RetryAfter(err error, after time.Duration) error {
+	return &RetryAfterError{Err: err, After: after}
+}
+
+// ParseRetryAfter reads a Retry-After header value, either delay-seconds or
+// an HTTP date in any of the three formats http.ParseTime accepts, relative
+// to now.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
+
+// Attempt describes one finished attempt, for RetryOptions.OnAttempt.
+type Attempt struct {
+	Number   int
+	Err      error
+	Duration time.Duration
+	// Wait is the delay before the next attempt; zero when Retry stops.
+	Wait time.Duration
+}
+
+// RetryOptions configures Retry. The zero value makes up to three attempts
+// with exponential backoff from 100ms.
+type RetryOptions struct {
+	// MaxAttempts counts the first call; zero means 3.
+	MaxAttempts int
+	// Timeout bounds the whole retry loop, waits included; zero means none.
+	Timeout time.Duration
+	// AttemptTimeout bounds each attempt with DoWithTimeout; zero means none.
+	AttemptTimeout time.Duration
+	// Backoff defaults to ExponentialBackoff{Base: 100ms, Max: 10s}.
+	Backoff Backoff
+	// Retryable classifies errors, typically with errors.Is and errors.As.
+	// Nil retries everything except a PermanentError. A PermanentError is
+	// never retried either way.
+	Retryable func(error) bool
+	// OnAttempt is called after every attempt, for logging and metrics.
+	OnAttempt func(Attempt)
+}
+
+// Retry calls fn until it succeeds, returns a non-retryable error, runs out
+// of attempts or ctx ends. A non-retryable error is returned as is; the
+// other failures wrap the last attempt's error, plus the context error when
+// ctx or Timeout ended the loop. Retry gives up early when the next wait
+// would pass the deadline.
+func Retry(ctx context.Context, opts RetryOptions, fn func(context.Context) error) error {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = ExponentialBackoff{Base: 100 * time.Millisecond, Max: 10 * time.Second}
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var wait time.Duration
+	for n := 1; ; n++ {
+		start := time.Now()
+		var err error
+		if opts.AttemptTimeout > 0 {
+			err = DoWithTimeout(ctx, opts.AttemptTimeout, fn)
+		} else {
+			err = fn(ctx)
+		}
+		info := Attempt{Number: n, Err: err, Duration: time.Since(start)}
+		if err == nil || !opts.retryable(err) {
+			opts.report(info)
+			return err
+		}
+		if ctx.Err() != nil {
+			opts.report(info)
+			return fmt.Errorf("gave up after %d attempts: %w: %w", n, ctx.Err(), err)
+		}
+		if n >= opts.MaxAttempts {
+			opts.report(info)
+			return fmt.Errorf("gave up after %d attempts: %w", n, err)
+		}
+
+		wait = opts.Backoff.Next(n, wait)
+		var hint *RetryAfterError
+		if errors.As(err, &hint) {
+			wait = hint.After
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			opts.report(info)
+			return fmt.Errorf("gave up after %d attempts, next wait %v passes the deadline: %w: %w",
+				n, wait, context.DeadlineExceeded, err)
+		}
+		info.Wait = wait
+		opts.report(info)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up after %d attempts: %w: %w", n, ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (o RetryOptions) retryable(err error) bool {
+	var perm *PermanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+	return o.Retryable == nil || o.Retryable(err)
+}
+
+func (o RetryOptions) report(a Attempt) {
+	if o.OnAttempt != nil {
+		o.OnAttempt(a)
+	}
+}
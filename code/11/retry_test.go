@@ -0,0 +1,175 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+// failing returns an fn that fails with errs in order, then succeeds.
+func failing(calls *int, errs ...error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errDown := errors.New("service down")
+	fast := ConstantBackoff(time.Millisecond)
+	cases := []struct {
+		name      string
+		opts      RetryOptions
+		errs      []error
+		wantCalls int
+		wantErr   error // checked with errors.Is; nil means success
+	}{
+		{"first try", RetryOptions{Backoff: fast}, nil, 1, nil},
+		{"recovers", RetryOptions{Backoff: fast}, []error{errTransient, errTransient}, 3, nil},
+		{"exhausted", RetryOptions{MaxAttempts: 2, Backoff: fast}, []error{errTransient, errTransient, errTransient}, 2, errTransient},
+		{"permanent", RetryOptions{Backoff: fast}, []error{Permanent(errDown)}, 1, errDown},
+		{"classified permanent", RetryOptions{Backoff: fast, Retryable: func(err error) bool {
+			return errors.Is(err, errTransient)
+		}}, []error{errTransient, errDown}, 2, errDown},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := Retry(context.Background(), tc.opts, failing(&calls, tc.errs...))
+			if calls != tc.wantCalls {
+				t.Fatalf("calls=%d want %d", calls, tc.wantCalls)
+			}
+			if tc.wantErr == nil && err != nil || !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err=%v want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	var calls atomic.Int32 // DoWithTimeout may return before fn does
+	err := Retry(context.Background(), RetryOptions{
+		MaxAttempts:    2,
+		AttemptTimeout: 10 * time.Millisecond,
+		Backoff:        ConstantBackoff(0),
+	}, func(ctx context.Context) error {
+		calls.Add(1)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if calls.Load() != 2 || !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrAbandoned) {
+		t.Fatalf("calls=%d err=%v; a timed-out attempt should be retried", calls.Load(), err)
+	}
+}
+
+func TestRetryOverallTimeout(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	err := Retry(context.Background(), RetryOptions{
+		MaxAttempts: 100,
+		Timeout:     50 * time.Millisecond,
+		Backoff:     ConstantBackoff(20 * time.Millisecond),
+	}, func(context.Context) error { calls++; return errTransient })
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errTransient) {
+		t.Fatalf("err=%v want deadline and the last error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond || calls > 3 {
+		t.Fatalf("ran %d attempts in %v past a 50ms budget", calls, elapsed)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Retry(ctx, RetryOptions{MaxAttempts: 5, Backoff: ConstantBackoff(time.Hour)}, func(context.Context) error {
+		calls++
+		cancel()
+		return errTransient
+	})
+	if calls != 1 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("calls=%d err=%v", calls, err)
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var attempts []Attempt
+	calls := 0
+	err := Retry(context.Background(), RetryOptions{
+		Backoff:   ConstantBackoff(time.Hour),
+		OnAttempt: func(a Attempt) { attempts = append(attempts, a) },
+	}, failing(&calls, RetryAfter(errTransient, 5*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("err=%v", err)
+	}
+	if len(attempts) != 2 || attempts[0].Wait != 5*time.Millisecond || attempts[0].Number != 1 ||
+		!errors.Is(attempts[0].Err, errTransient) || attempts[1].Err != nil || attempts[1].Wait != 0 {
+		t.Fatalf("attempts=%+v", attempts)
+	}
+
+	// A hint beyond the deadline ends the loop without sleeping.
+	start := time.Now()
+	err = Retry(context.Background(), RetryOptions{Timeout: time.Second}, func(context.Context) error {
+		return RetryAfter(errTransient, time.Minute)
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("err=%v after %v", err, time.Since(start))
+	}
+}
+
+func TestBackoffPolicies(t *testing.T) {
+	exp := ExponentialBackoff{Base: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+	for attempt, want := range []time.Duration{10, 20, 40, 50, 50} {
+		if got := exp.Next(attempt+1, 0); got != want*time.Millisecond {
+			t.Fatalf("exponential attempt %d: %v want %v", attempt+1, got, want*time.Millisecond)
+		}
+	}
+	if got := (ExponentialBackoff{Base: time.Second}).Next(200, 0); got <= 0 {
+		t.Fatalf("exponential overflowed to %v", got)
+	}
+
+	jitter := DecorrelatedJitter{Base: 10 * time.Millisecond, Max: time.Second}
+	prev := time.Duration(0)
+	for i := 1; i <= 50; i++ {
+		d := jitter.Next(i, prev)
+		if d < jitter.Base || d > jitter.Max || d > max(3*prev, jitter.Base) {
+			t.Fatalf("jitter step %d: %v outside [%v, %v]", i, d, jitter.Base, max(3*prev, jitter.Base))
+		}
+		prev = d
+	}
+
+	if got := ConstantBackoff(time.Second).Next(7, time.Hour); got != time.Second {
+		t.Fatalf("constant: %v", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"Wed, 01 May 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Wed, 01 May 2024 11:00:00 GMT", 0, true},
+		{"Wednesday, 01-May-24 12:01:00 GMT", time.Minute, true},
+		{"Wed May  1 12:00:05 2024", 5 * time.Second, true},
+		{"Wed, 01 May 2024 12:00:30 CEST", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := ParseRetryAfter(tc.in, now)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("%q: got %v, %v want %v, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package concurrency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrWeightTooLarge is returned by Acquire when n exceeds the semaphore's
+	// size, so the call could never succeed.
+	ErrWeightTooLarge = errors.New("semaphore: weight exceeds size")
+	// ErrInvalidWeight is returned by Acquire when n is not positive; a
+	// negative weight would otherwise grow the capacity.
+	ErrInvalidWeight = errors.New("semaphore: weight must be positive")
+)
+
+// Semaphore is a weighted semaphore: callers acquire n units of a fixed
+// capacity, for example the estimated cost of an upstream stream. Waiters
+// are served in FIFO order, so a large request is not starved by a steady
+// flow of small ones.
+type Semaphore struct {
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	waiters list.List // of semWaiter
+}
+
+type semWaiter struct {
+	n     int64
+	ready chan struct{} // closed once the units are granted
+}
+
+// NewSemaphore returns a semaphore with size units.
+func NewSemaphore(size int64) *Semaphore {
+	return &Semaphore{size: size}
+}
+
+// Acquire blocks until n units are available or ctx is done. On failure it
+// returns ctx.Err(), ErrInvalidWeight or ErrWeightTooLarge and holds nothing.
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	switch {
+	case n <= 0:
+		return ErrInvalidWeight
+	case n > s.size:
+		return ErrWeightTooLarge
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	w := semWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// Granted while we were giving up: hand the units back.
+			s.cur -= n
+		default:
+			s.waiters.Remove(elem)
+		}
+		// Either way the waiters behind us may now fit.
+		s.notify()
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes n units without blocking and reports whether it did. It
+// is always false for n <= 0 or n above the size.
+func (s *Semaphore) TryAcquire(n int64) bool {
+	if n <= 0 || n > s.size {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release returns n units. Releasing n <= 0 or more than is held panics.
+func (s *Semaphore) Release(n int64) {
+	if n <= 0 {
+		panic("semaphore: release of non-positive weight")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > s.cur {
+		panic("semaphore: released more than held")
+	}
+	s.cur -= n
+	s.notify()
+}
+
+// Available reports the units not currently held.
+func (s *Semaphore) Available() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size - s.cur
+}
+
+// notify grants units to waiters from the front of the queue while they
+// fit. It stops at the first one that does not, to keep FIFO order.
+func (s *Semaphore) notify() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(semWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
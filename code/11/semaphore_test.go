@@ -0,0 +1,139 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/go-class/11/leaktest"
+)
+
+func TestSemaphoreLimitsWeight(t *testing.T) {
+	leaktest.Check(t)
+	sem := NewSemaphore(10)
+	var inUse, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		n := int64(i%4 + 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sem.Acquire(context.Background(), n); err != nil {
+				t.Error(err)
+				return
+			}
+			cur := inUse.Add(n)
+			for {
+				p := peak.Load()
+				if cur <= p || peak.CompareAndSwap(p, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			inUse.Add(-n)
+			sem.Release(n)
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p > 10 {
+		t.Fatalf("peak weight %d exceeds size 10", p)
+	}
+	if a := sem.Available(); a != 10 {
+		t.Fatalf("Available()=%d after all releases", a)
+	}
+}
+
+func TestSemaphoreTryAcquire(t *testing.T) {
+	sem := NewSemaphore(3)
+	cases := []struct {
+		n    int64
+		want bool
+	}{{2, true}, {2, false}, {1, true}, {1, false}}
+	for _, tc := range cases {
+		if got := sem.TryAcquire(tc.n); got != tc.want {
+			t.Fatalf("TryAcquire(%d)=%v want %v", tc.n, got, tc.want)
+		}
+	}
+	sem.Release(3)
+	if err := sem.Acquire(context.Background(), 4); !errors.Is(err, ErrWeightTooLarge) {
+		t.Fatalf("Acquire(4) on size 3: %v", err)
+	}
+}
+
+func TestSemaphoreFIFOAndCancel(t *testing.T) {
+	leaktest.Check(t)
+	sem := NewSemaphore(4)
+	sem.TryAcquire(3)
+
+	// A large waiter queues first; a later small request must not jump it.
+	bigDone := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { bigDone <- sem.Acquire(ctx, 4) }()
+	waitFor(t, func() bool { return sem.waiting() == 1 })
+	if sem.TryAcquire(1) {
+		t.Fatalf("TryAcquire overtook a queued waiter")
+	}
+	smallDone := make(chan error, 1)
+	go func() { smallDone <- sem.Acquire(context.Background(), 1) }()
+	waitFor(t, func() bool { return sem.waiting() == 2 })
+
+	// Cancelling the large waiter lets the small one through.
+	cancel()
+	if err := <-bigDone; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Acquire=%v", err)
+	}
+	if err := <-smallDone; err != nil {
+		t.Fatal(err)
+	}
+	if a := sem.Available(); a != 0 {
+		t.Fatalf("Available()=%d want 0", a)
+	}
+	sem.Release(4)
+}
+
+func TestSemaphoreReleaseTooMuch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Release without Acquire should panic")
+		}
+	}()
+	NewSemaphore(1).Release(1)
+}
+
+func TestSemaphoreRejectsBadWeights(t *testing.T) {
+	sem := NewSemaphore(3)
+	for _, n := range []int64{0, -1, 4} {
+		want := ErrInvalidWeight
+		if n > 0 {
+			want = ErrWeightTooLarge
+		}
+		if err := sem.Acquire(context.Background(), n); !errors.Is(err, want) {
+			t.Fatalf("Acquire(%d)=%v want %v", n, err, want)
+		}
+		if sem.TryAcquire(n) {
+			t.Fatalf("TryAcquire(%d) succeeded", n)
+		}
+	}
+	for _, n := range []int64{0, -2} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Release(%d) should panic", n)
+				}
+			}()
+			sem.Release(n)
+		}()
+	}
+	if a := sem.Available(); a != 3 {
+		t.Fatalf("Available()=%d want the size to stay 3", a)
+	}
+}
+
+func (s *Semaphore) waiting() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiters.Len()
+}
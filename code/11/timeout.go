@@ -2,23 +2,152 @@ package concurrency
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
 	"time"
 )
 
+// ErrAbandoned is wrapped into the context error when DoWithTimeoutOptions
+// returns while fn is still running after the grace period.
+var ErrAbandoned = errors.New("fn still running after the deadline")
+
 // DoWithTimeout runs fn with a derived context that times out.
 func DoWithTimeout(parent context.Context, timeout time.Duration, fn func(context.Context) error) error {
-	ctx, cancel := context.WithTimeout(parent, timeout)
+	return doWithTimeout(parent, TimeoutOptions{Timeout: timeout}, fn, 2)
+}
+
+// TimeoutOptions configures DoWithTimeoutOptions.
+type TimeoutOptions struct {
+	Timeout time.Duration
+	// Grace is how long to keep waiting for fn to return once the context is
+	// done, so cooperative functions can clean up before the caller moves on.
+	// Orphans are only tracked with a positive Grace: without one, a fn that
+	// honours its context cannot be told from one that ignores it.
+	Grace time.Duration
+	// OnOrphan is called when fn is still running after Grace. The orphan
+	// also shows up in Orphans until fn finally returns.
+	OnOrphan func(Orphan)
+}
+
+// Orphan is a fn that DoWithTimeoutOptions gave up on while it was still
+// running after the grace period.
+type Orphan struct {
+	// Caller is the file:line that called DoWithTimeout.
+	Caller    string
+	Started   time.Time
+	Abandoned time.Time
+}
+
+// DoWithTimeoutOptions is DoWithTimeout with a grace period and orphan
+// reporting. If fn ignores its context and is still running when the grace
+// period ends, the error wraps both ctx.Err() and ErrAbandoned.
+func DoWithTimeoutOptions(parent context.Context, opts TimeoutOptions, fn func(context.Context) error) error {
+	return doWithTimeout(parent, opts, fn, 2)
+}
+
+func doWithTimeout(parent context.Context, opts TimeoutOptions, fn func(context.Context) error, skip int) error {
+	ctx, cancel := context.WithTimeout(parent, opts.Timeout)
 	defer cancel()
-	done := make(chan error, 1)
+	started := time.Now()
+	c := &call{done: make(chan error, 1)}
 
 	go func() {
-		done <- fn(ctx)
+		err := fn(ctx)
+		c.finish()
+		c.done <- err
 	}()
 
 	select {
+	case err := <-c.done:
+		return err
 	case <-ctx.Done():
+	}
+	if opts.Grace <= 0 {
 		return ctx.Err()
-	case err := <-done:
-		return err
 	}
+	timer := time.NewTimer(opts.Grace)
+	defer timer.Stop()
+	select {
+	case <-c.done:
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	o := Orphan{Started: started, Abandoned: time.Now()}
+	if _, file, line, ok := runtime.Caller(skip); ok {
+		o.Caller = fmt.Sprintf("%s:%d", file, line)
+	}
+	if !c.abandon(o) {
+		return ctx.Err() // fn returned just now
+	}
+	if opts.OnOrphan != nil {
+		opts.OnOrphan(o)
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), ErrAbandoned)
+}
+
+// call tracks one fn so that it leaves the orphan registry when it returns.
+type call struct {
+	done     chan error
+	mu       sync.Mutex
+	finished bool
+	orphanID uint64
+}
+
+func (c *call) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finished = true
+	if c.orphanID != 0 {
+		orphans.remove(c.orphanID)
+	}
+}
+
+// abandon registers o unless fn has already returned.
+func (c *call) abandon(o Orphan) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished {
+		return false
+	}
+	c.orphanID = orphans.add(o)
+	return true
+}
+
+var orphans = &orphanRegistry{m: make(map[uint64]Orphan)}
+
+type orphanRegistry struct {
+	mu   sync.Mutex
+	next uint64
+	m    map[uint64]Orphan
+}
+
+func (r *orphanRegistry) add(o Orphan) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	r.m[r.next] = o
+	return r.next
+}
+
+func (r *orphanRegistry) remove(id uint64) {
+	r.mu.Lock()
+	delete(r.m, id)
+	r.mu.Unlock()
+}
+
+// Orphans lists the functions DoWithTimeoutOptions abandoned that are still
+// running, oldest first. A growing list means some fn ignores its context.
+func Orphans() []Orphan {
+	orphans.mu.Lock()
+	list := make([]Orphan, 0, len(orphans.m))
+	for _, o := range orphans.m {
+		list = append(list, o)
+	}
+	orphans.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
 }
@@ -3,11 +3,15 @@ package concurrency
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
+
+	"example.com/go-class/11/leaktest"
 )
 
 func TestDoWithTimeoutSuccess(t *testing.T) {
+	leaktest.Check(t)
 	err := DoWithTimeout(context.Background(), 50*time.Millisecond, func(ctx context.Context) error {
 		select {
 		case <-time.After(10 * time.Millisecond):
@@ -22,6 +26,7 @@ func TestDoWithTimeoutSuccess(t *testing.T) {
 }
 
 func TestDoWithTimeoutExceeded(t *testing.T) {
+	leaktest.Check(t)
 	err := DoWithTimeout(context.Background(), 10*time.Millisecond, func(ctx context.Context) error {
 		select {
 		case <-time.After(50 * time.Millisecond):
@@ -34,3 +39,77 @@ func TestDoWithTimeoutExceeded(t *testing.T) {
 		t.Fatalf("expected deadline exceeded, got %v", err)
 	}
 }
+
+func TestDoWithTimeoutGrace(t *testing.T) {
+	leaktest.Check(t)
+	cleanedUp := false
+	err := DoWithTimeoutOptions(context.Background(), TimeoutOptions{
+		Timeout: 10 * time.Millisecond,
+		Grace:   time.Second,
+		OnOrphan: func(Orphan) {
+			t.Errorf("a function that returns within the grace period is not an orphan")
+		},
+	}, func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond) // slow cleanup
+		cleanedUp = true
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrAbandoned) {
+		t.Fatalf("err=%v", err)
+	}
+	if !cleanedUp {
+		t.Fatalf("returned before fn finished its cleanup")
+	}
+}
+
+func TestDoWithTimeoutOrphan(t *testing.T) {
+	leaktest.Check(t)
+	release := make(chan struct{})
+	var reported []Orphan
+	err := DoWithTimeoutOptions(context.Background(), TimeoutOptions{
+		Timeout:  5 * time.Millisecond,
+		Grace:    5 * time.Millisecond,
+		OnOrphan: func(o Orphan) { reported = append(reported, o) },
+	}, func(ctx context.Context) error {
+		<-release // ignores ctx
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrAbandoned) {
+		t.Fatalf("err=%v want DeadlineExceeded and ErrAbandoned", err)
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0].Caller, "timeout_test.go") {
+		t.Fatalf("reported=%+v want one orphan from this file", reported)
+	}
+	if list := Orphans(); len(list) != 1 || list[0] != reported[0] {
+		t.Fatalf("Orphans()=%+v", list)
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for len(Orphans()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("orphan still listed after fn returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDoWithTimeoutWithoutGraceTracksNoOrphans(t *testing.T) {
+	leaktest.Check(t)
+	for i := 0; i < 200; i++ {
+		err := DoWithTimeoutOptions(context.Background(), TimeoutOptions{
+			Timeout:  time.Microsecond,
+			OnOrphan: func(Orphan) { t.Errorf("orphan reported without a grace period") },
+		}, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrAbandoned) {
+			t.Fatalf("run %d: err=%v want a plain DeadlineExceeded", i, err)
+		}
+	}
+	if list := Orphans(); len(list) != 0 {
+		t.Fatalf("Orphans()=%+v", list)
+	}
+}
@@ -3,7 +3,9 @@ package concurrency
 import (
 	"context"
 	"errors"
+	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
 // ProcessWithPool runs jobs concurrently with at most `workers` goroutines.
@@ -12,58 +14,277 @@ func ProcessWithPool(ctx context.Context, inputs []int, workers int) ([]int, err
 	if workers <= 0 {
 		return nil, errors.New("workers must be positive")
 	}
-	type job struct {
-		idx int
-		val int
+	square := func(_ context.Context, v int) (int, error) { return v * v, nil }
+	results, err := NewPool(square, PoolOptions{Workers: workers}).Run(ctx, inputs)
+	out := make([]int, len(results))
+	for i, r := range results {
+		out[i] = r.Value
 	}
+	return out, err
+}
 
-	jobs := make(chan job)
-	results := make([]int, len(inputs))
-	var wg sync.WaitGroup
+var (
+	// ErrQueueFull is returned by Submit when RejectWhenFull is set and no
+	// queue slot is free.
+	ErrQueueFull = errors.New("worker pool queue is full")
+	// ErrPoolClosed is returned by Submit after Close or Shutdown, and by
+	// futures of queued jobs that Shutdown abandoned.
+	ErrPoolClosed = errors.New("worker pool is closed")
+)
 
-	worker := func() {
-		defer wg.Done()
-		for j := range jobs {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-			}
-			results[j.idx] = j.val * j.val
-		}
+// WorkerPoolOptions configures a WorkerPool.
+type WorkerPoolOptions struct {
+	// Workers is the initial number of workers; zero means GOMAXPROCS.
+	Workers int
+	// QueueSize bounds the jobs waiting for a worker. Zero means a job is only
+	// accepted when a worker is ready to take it.
+	QueueSize int
+	// RejectWhenFull makes Submit fail with ErrQueueFull instead of waiting
+	// for a queue slot.
+	RejectWhenFull bool
+	// JobOptions recovers panics as *PanicError and sets per-job deadlines
+	// and the slow-job watchdog.
+	JobOptions
+}
+
+// WorkerPool is a long-lived pool that runs fn for each submitted job.
+// Unlike Pool, which processes one slice and exits, it keeps its workers
+// until Close or Shutdown, can be resized while running, and hands out a
+// Future per job.
+type WorkerPool[In, Out any] struct {
+	fn     func(context.Context, In) (Out, error)
+	opts   WorkerPoolOptions
+	queue  chan *task[In, Out]
+	ctx    context.Context // jobs run under it; Shutdown cancels it on timeout
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	closing chan struct{} // closed by Close to wake blocked submitters
+	quits   []chan struct{}
+	workers sync.WaitGroup
+	submits sync.WaitGroup
+	done    chan struct{} // closed once every worker has exited
+
+	running, busy, submitted, completed, failed atomic.Int64
+}
+
+type task[In, Out any] struct {
+	in     In
+	future *Future[Out]
+}
+
+// Future is the pending result of a submitted job.
+type Future[Out any] struct {
+	done  chan struct{}
+	value Out
+	err   error
+}
+
+// Done is closed when the job has finished.
+func (f *Future[Out]) Done() <-chan struct{} { return f.done }
+
+// Wait blocks until the job finishes or ctx ends. Giving up on the wait
+// does not cancel the job.
+func (f *Future[Out]) Wait(ctx context.Context) (Out, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero Out
+		return zero, ctx.Err()
+	}
+}
+
+func (f *Future[Out]) resolve(v Out, err error) {
+	f.value, f.err = v, err
+	close(f.done)
+}
+
+// PoolStats is a point-in-time view of a WorkerPool.
+type PoolStats struct {
+	Workers   int // running workers, including ones Resize removed that are finishing a job; 0 once closed
+	Busy      int // workers running a job
+	Queued    int // jobs waiting for a worker
+	QueueCap  int
+	Submitted int64
+	Completed int64 // jobs that returned, including failed ones
+	Failed    int64 // jobs that returned an error
+}
+
+// Utilization is the fraction of workers running a job.
+func (s PoolStats) Utilization() float64 {
+	if s.Workers == 0 {
+		return 0
 	}
+	return float64(s.Busy) / float64(s.Workers)
+}
+
+// NewWorkerPool starts a pool that applies fn to submitted jobs.
+func NewWorkerPool[In, Out any](fn func(context.Context, In) (Out, error), opts WorkerPoolOptions) *WorkerPool[In, Out] {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &WorkerPool[In, Out]{
+		fn:      fn,
+		opts:    opts,
+		queue:   make(chan *task[In, Out], max(opts.QueueSize, 0)),
+		ctx:     ctx,
+		cancel:  cancel,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	p.Resize(opts.Workers)
+	return p
+}
 
-	wg.Add(workers)
-	for i := 0; i < workers; i++ {
-		go worker()
+// Submit queues in and returns its Future. When the queue is full it waits
+// for a slot until ctx ends, or fails with ErrQueueFull if RejectWhenFull is
+// set. ctx only bounds the wait to enqueue; the job itself runs under the
+// pool's context.
+func (p *WorkerPool[In, Out]) Submit(ctx context.Context, in In) (*Future[Out], error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
 	}
+	p.submits.Add(1)
+	p.mu.Unlock()
+	defer p.submits.Done()
 
-	for i, v := range inputs {
-		if err := ctx.Err(); err != nil {
-			close(jobs)
-			wg.Wait()
-			return results, err
+	t := &task[In, Out]{in: in, future: &Future[Out]{done: make(chan struct{})}}
+	if p.opts.RejectWhenFull {
+		select {
+		case p.queue <- t:
+		default:
+			return nil, ErrQueueFull
 		}
+	} else {
 		select {
+		case p.queue <- t:
 		case <-ctx.Done():
-			close(jobs)
-			wg.Wait()
-			return results, ctx.Err()
-		case jobs <- job{idx: i, val: v}:
+			return nil, ctx.Err()
+		case <-p.closing:
+			return nil, ErrPoolClosed
 		}
 	}
-	close(jobs)
-	wg.Wait()
-	if err := ctx.Err(); err != nil {
-		return results, err
+	p.submitted.Add(1)
+	return t.future, nil
+}
+
+// Resize changes the number of workers; n below one is treated as one.
+// Removed workers finish their current job first. It is a no-op once the
+// pool is closed.
+func (p *WorkerPool[In, Out]) Resize(n int) {
+	n = max(n, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	for len(p.quits) < n {
+		quit := make(chan struct{})
+		p.quits = append(p.quits, quit)
+		p.workers.Add(1)
+		p.running.Add(1)
+		go p.work(quit)
+	}
+	for len(p.quits) > n {
+		last := len(p.quits) - 1
+		close(p.quits[last])
+		p.quits = p.quits[:last]
 	}
-	return results, nil
 }
 
-/*
-results 没有数据竞争的原因：
+func (p *WorkerPool[In, Out]) work(quit <-chan struct{}) {
+	defer p.workers.Done()
+	defer p.running.Add(-1)
+	for {
+		// Prefer quitting over taking another job when both are possible.
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		select {
+		case <-quit:
+			return
+		case t, ok := <-p.queue:
+			if !ok {
+				return
+			}
+			p.run(t)
+		}
+	}
+}
 
-- 每个元素只会被写一次：job{idx, val} 在生产时就绑定了唯一的 idx，jobs 通道的元素只能被某一个 worker 取到，因此同一 idx 不会被两个 goroutine 同时写。
-- 主 goroutine 不读 results，直到 wg.Wait() 完成所有 worker；写入阶段只有 worker 在写，读取阶段只有主 goroutine 在读，读写不重叠。
-- 取消场景下，worker 直接返回，不会和其他 worker 同时写同一个下标，只是可能留下默认值 0。
-*/
+func (p *WorkerPool[In, Out]) run(t *task[In, Out]) {
+	if p.ctx.Err() != nil {
+		var zero Out
+		t.future.resolve(zero, ErrPoolClosed)
+		return
+	}
+	p.busy.Add(1)
+	defer p.busy.Add(-1)
+	v, err := RunJob(p.ctx, p.opts.JobOptions, p.fn, t.in)
+	p.completed.Add(1)
+	if err != nil {
+		p.failed.Add(1)
+	}
+	t.future.resolve(v, err)
+}
+
+// Stats reports the pool's current load and lifetime counters.
+func (p *WorkerPool[In, Out]) Stats() PoolStats {
+	return PoolStats{
+		Workers:   int(p.running.Load()),
+		Busy:      int(p.busy.Load()),
+		Queued:    len(p.queue),
+		QueueCap:  cap(p.queue),
+		Submitted: p.submitted.Load(),
+		Completed: p.completed.Load(),
+		Failed:    p.failed.Load(),
+	}
+}
+
+// Close stops accepting jobs and waits until every queued and running job
+// has finished. It always returns nil and is safe to call more than once.
+func (p *WorkerPool[In, Out]) Close() error {
+	p.startClose()
+	<-p.done
+	return nil
+}
+
+// Shutdown is Close bounded by ctx. If ctx ends first it cancels the
+// context of running jobs, fails the still-queued ones with ErrPoolClosed
+// and returns ctx.Err() without waiting for the workers to exit.
+func (p *WorkerPool[In, Out]) Shutdown(ctx context.Context) error {
+	p.startClose()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+func (p *WorkerPool[In, Out]) startClose() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.closing)
+	go func() {
+		// No Submit can be sending once submits drains, so closing the queue
+		// is safe; workers exit after taking what is left in it.
+		p.submits.Wait()
+		close(p.queue)
+		p.workers.Wait()
+		p.cancel()
+		close(p.done)
+	}()
+}
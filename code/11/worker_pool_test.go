@@ -2,11 +2,16 @@ package concurrency
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"example.com/go-class/11/leaktest"
 )
 
 func TestProcessWithPool(t *testing.T) {
+	leaktest.Check(t)
 	ctx := context.Background()
 	inputs := []int{1, 2, 3, 4, 5}
 	workers := 2
@@ -24,6 +29,7 @@ func TestProcessWithPool(t *testing.T) {
 }
 
 func TestProcessWithPoolCancel(t *testing.T) {
+	leaktest.Check(t)
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Nanosecond)
 	defer cancel()
 
@@ -39,3 +45,196 @@ func TestProcessWithPoolInvalidWorkerCount(t *testing.T) {
 		t.Fatalf("expected error for non-positive workers")
 	}
 }
+
+// gate blocks jobs until it is opened, so tests control when workers free up.
+type gate chan struct{}
+
+func (g gate) job(ctx context.Context, n int) (int, error) {
+	select {
+	case <-g:
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return n * 2, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func TestWorkerPoolFutures(t *testing.T) {
+	leaktest.Check(t)
+	g := make(gate)
+	close(g)
+	p := NewWorkerPool(g.job, WorkerPoolOptions{Workers: 3, QueueSize: 10})
+	defer p.Close()
+
+	ctx := context.Background()
+	var futures []*Future[int]
+	for i := -1; i < 10; i++ {
+		f, err := p.Submit(ctx, i)
+		if err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+		futures = append(futures, f)
+	}
+	for i, f := range futures {
+		n := i - 1
+		v, err := f.Wait(ctx)
+		if n < 0 {
+			if err == nil {
+				t.Fatalf("job %d: expected error", n)
+			}
+			continue
+		}
+		if err != nil || v != n*2 {
+			t.Fatalf("job %d: got %d, %v", n, v, err)
+		}
+	}
+	if st := p.Stats(); st.Submitted != 11 || st.Completed != 11 || st.Failed != 1 {
+		t.Fatalf("stats=%+v", st)
+	}
+}
+
+func TestWorkerPoolBackpressure(t *testing.T) {
+	g := make(gate)
+	p := NewWorkerPool(g.job, WorkerPoolOptions{Workers: 1, QueueSize: 1, RejectWhenFull: true})
+	defer p.Close()
+	defer close(g)
+
+	ctx := context.Background()
+	if _, err := p.Submit(ctx, 1); err != nil { // taken by the worker
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return p.Stats().Busy == 1 })
+	if _, err := p.Submit(ctx, 2); err != nil { // fills the queue
+		t.Fatal(err)
+	}
+	if _, err := p.Submit(ctx, 3); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err=%v want ErrQueueFull", err)
+	}
+	st := p.Stats()
+	if st.Queued != 1 || st.QueueCap != 1 || st.Utilization() != 1 {
+		t.Fatalf("stats=%+v", st)
+	}
+}
+
+func TestWorkerPoolSubmitBlocks(t *testing.T) {
+	g := make(gate)
+	p := NewWorkerPool(g.job, WorkerPoolOptions{Workers: 1})
+	defer p.Close()
+	defer close(g)
+
+	if _, err := p.Submit(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Submit(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err=%v want DeadlineExceeded while the only worker is busy", err)
+	}
+}
+
+func TestWorkerPoolCloseDrains(t *testing.T) {
+	leaktest.Check(t)
+	var done atomic.Int32
+	slow := func(ctx context.Context, n int) (int, error) {
+		time.Sleep(5 * time.Millisecond)
+		done.Add(1)
+		return n, nil
+	}
+	p := NewWorkerPool(slow, WorkerPoolOptions{Workers: 2, QueueSize: 20})
+	for i := 0; i < 20; i++ {
+		if _, err := p.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if done.Load() != 20 {
+		t.Fatalf("Close returned after %d of 20 jobs", done.Load())
+	}
+	if _, err := p.Submit(context.Background(), 1); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("submit after close: %v", err)
+	}
+	_ = p.Close() // idempotent
+}
+
+func TestWorkerPoolShutdownTimeout(t *testing.T) {
+	g := make(gate) // never opened: jobs only end through cancellation
+	p := NewWorkerPool(g.job, WorkerPoolOptions{Workers: 1, QueueSize: 5})
+	running, _ := p.Submit(context.Background(), 1)
+	queued, _ := p.Submit(context.Background(), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("shutdown err=%v", err)
+	}
+	if _, err := running.Wait(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("running job err=%v want context.Canceled", err)
+	}
+	if _, err := queued.Wait(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("queued job err=%v want ErrPoolClosed", err)
+	}
+}
+
+func TestWorkerPoolResize(t *testing.T) {
+	g := make(gate)
+	p := NewWorkerPool(g.job, WorkerPoolOptions{Workers: 1, QueueSize: 10})
+	defer p.Close()
+
+	for i := 0; i < 4; i++ {
+		if _, err := p.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Resize(4)
+	waitFor(t, func() bool { return p.Stats().Busy == 4 })
+	p.Resize(2)
+	if st := p.Stats(); st.Workers != 4 || st.Busy != 4 {
+		t.Fatalf("after shrink: %+v; removed workers should finish their job", st)
+	}
+	close(g)
+	waitFor(t, func() bool { return p.Stats().Workers == 2 && p.Stats().Busy == 0 })
+	p.Resize(0)
+	waitFor(t, func() bool { return p.Stats().Workers == 1 })
+}
+
+func TestWorkerPoolStatsAfterClose(t *testing.T) {
+	leaktest.Check(t)
+	open := make(gate)
+	close(open)
+	p := NewWorkerPool(open.job, WorkerPoolOptions{Workers: 3})
+	if got := p.Stats().Workers; got != 3 {
+		t.Fatalf("workers=%d want 3", got)
+	}
+	p.Close()
+	if st := p.Stats(); st.Workers != 0 || st.Utilization() != 0 {
+		t.Fatalf("after Close: %+v", st)
+	}
+
+	// Shutdown gives up on a stuck job; its worker counts until it exits.
+	g := make(gate)
+	p = NewWorkerPool(g.job, WorkerPoolOptions{Workers: 2})
+	f, _ := p.Submit(context.Background(), 1)
+	waitFor(t, func() bool { return p.Stats().Busy == 1 })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("shutdown err=%v", err)
+	}
+	_, _ = f.Wait(context.Background())
+	waitFor(t, func() bool { return p.Stats().Workers == 0 })
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,298 @@
+package patterns
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrBrokerClosed is returned by Publish and Subscribe after Close, and
+	// by Subscription.Err once Close ended the subscription.
+	ErrBrokerClosed = errors.New("broker is closed")
+	// ErrSlowConsumer is Subscription.Err after the Disconnect policy dropped
+	// the subscriber.
+	ErrSlowConsumer = errors.New("subscriber disconnected: buffer full")
+)
+
+// SlowConsumerPolicy decides what Publish does when a subscriber's buffer
+// is full.
+type SlowConsumerPolicy int
+
+const (
+	// DropOldest discards the oldest buffered event to make room.
+	DropOldest SlowConsumerPolicy = iota
+	// DropNewest discards the event being published.
+	DropNewest
+	// BlockWithTimeout waits up to SubscriberOptions.BlockTimeout for room,
+	// then drops the event. Publish is delayed for everyone meanwhile.
+	BlockWithTimeout
+	// Disconnect ends the subscription; its channel is closed and Err
+	// returns ErrSlowConsumer.
+	Disconnect
+)
+
+// SubscriberOptions configures one subscription.
+type SubscriberOptions struct {
+	// Buffer is the subscriber's channel capacity; zero means 16.
+	Buffer int
+	Policy SlowConsumerPolicy
+	// BlockTimeout is used by BlockWithTimeout; zero means 100ms.
+	BlockTimeout time.Duration
+}
+
+// Broker distributes events published on a topic to every subscriber of
+// that topic. Each subscriber has its own buffer and slow-consumer policy,
+// so one stuck reader does not hold up the others beyond its policy.
+type Broker[T any] struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription[T]]struct{}
+	closed bool
+
+	published, delivered, dropped, disconnected atomic.Int64
+}
+
+// BrokerStats counts events since the broker started.
+type BrokerStats struct {
+	Topics       int
+	Subscribers  int
+	Published    int64 // Publish calls
+	Delivered    int64 // events handed to a subscriber
+	Dropped      int64 // events lost to DropOldest, DropNewest or a block timeout
+	Disconnected int64 // subscribers removed by Disconnect
+}
+
+// NewBroker returns an empty broker.
+func NewBroker[T any]() *Broker[T] {
+	return &Broker[T]{topics: make(map[string]map[*Subscription[T]]struct{})}
+}
+
+// Subscribe registers a subscriber for topic.
+func (b *Broker[T]) Subscribe(topic string, opts SubscriberOptions) (*Subscription[T], error) {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 16
+	}
+	if opts.BlockTimeout <= 0 {
+		opts.BlockTimeout = 100 * time.Millisecond
+	}
+	s := &Subscription[T]{
+		Topic:  topic,
+		broker: b,
+		opts:   opts,
+		ch:     make(chan T, opts.Buffer),
+		done:   make(chan struct{}),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+	subs := b.topics[topic]
+	if subs == nil {
+		subs = make(map[*Subscription[T]]struct{})
+		b.topics[topic] = subs
+	}
+	subs[s] = struct{}{}
+	return s, nil
+}
+
+// Publish delivers v to every current subscriber of topic and reports how
+// many received it.
+func (b *Broker[T]) Publish(topic string, v T) (int, error) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return 0, ErrBrokerClosed
+	}
+	subs := make([]*Subscription[T], 0, len(b.topics[topic]))
+	for s := range b.topics[topic] {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	b.published.Add(1)
+	delivered := 0
+	for _, s := range subs {
+		if s.deliver(v) {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// Stats reports the broker's current size and lifetime counters.
+func (b *Broker[T]) Stats() BrokerStats {
+	b.mu.RLock()
+	st := BrokerStats{Topics: len(b.topics)}
+	for _, subs := range b.topics {
+		st.Subscribers += len(subs)
+	}
+	b.mu.RUnlock()
+	st.Published = b.published.Load()
+	st.Delivered = b.delivered.Load()
+	st.Dropped = b.dropped.Load()
+	st.Disconnected = b.disconnected.Load()
+	return st
+}
+
+// Close ends every subscription, closing their channels, and rejects
+// further Publish and Subscribe calls. Buffered events can still be read.
+func (b *Broker[T]) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	var subs []*Subscription[T]
+	for _, set := range b.topics {
+		for s := range set {
+			subs = append(subs, s)
+		}
+	}
+	b.topics = nil
+	b.mu.Unlock()
+	for _, s := range subs {
+		s.close(ErrBrokerClosed)
+	}
+}
+
+func (b *Broker[T]) remove(s *Subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if subs, ok := b.topics[s.Topic]; ok {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(b.topics, s.Topic)
+		}
+	}
+}
+
+// Subscription is one subscriber's view of a topic.
+type Subscription[T any] struct {
+	Topic string
+
+	broker *Broker[T]
+	opts   SubscriberOptions
+	ch     chan T
+	// done is closed before mu is taken, so a deliver blocked while holding
+	// mu gives up and teardown does not wait for BlockTimeout.
+	done     chan struct{}
+	doneOnce sync.Once
+
+	mu     sync.Mutex // serializes deliveries with closing ch
+	closed bool
+	err    error
+
+	delivered, dropped atomic.Int64
+}
+
+// C receives the events; it is closed when the subscription ends.
+func (s *Subscription[T]) C() <-chan T { return s.ch }
+
+// Err explains why C was closed: nil after Unsubscribe, ErrSlowConsumer or
+// ErrBrokerClosed.
+func (s *Subscription[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Delivered counts events queued for this subscriber.
+func (s *Subscription[T]) Delivered() int64 { return s.delivered.Load() }
+
+// Dropped counts events this subscriber lost to its policy.
+func (s *Subscription[T]) Dropped() int64 { return s.dropped.Load() }
+
+// Unsubscribe stops delivery and closes C. It is safe to call more than once.
+func (s *Subscription[T]) Unsubscribe() {
+	s.broker.remove(s)
+	s.close(nil)
+}
+
+func (s *Subscription[T]) close(err error) {
+	s.stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.ch)
+}
+
+func (s *Subscription[T]) stop() {
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+// deliver applies the subscriber's policy and reports whether v was queued.
+func (s *Subscription[T]) deliver(v T) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+	select {
+	case s.ch <- v:
+		s.mu.Unlock()
+		s.count(true)
+		return true
+	default:
+	}
+
+	switch s.opts.Policy {
+	case DropNewest:
+		s.mu.Unlock()
+		s.count(false)
+		return false
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- v:
+				s.mu.Unlock()
+				s.count(true)
+				return true
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.count(false)
+			default: // the reader just made room
+			}
+		}
+	case BlockWithTimeout:
+		defer s.mu.Unlock()
+		timer := time.NewTimer(s.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case s.ch <- v:
+			s.count(true)
+			return true
+		case <-timer.C:
+		case <-s.done:
+		}
+		s.count(false)
+		return false
+	default: // Disconnect
+		s.closed = true
+		s.err = ErrSlowConsumer
+		s.stop()
+		close(s.ch)
+		s.mu.Unlock()
+		s.broker.remove(s)
+		s.broker.disconnected.Add(1)
+		return false
+	}
+}
+
+func (s *Subscription[T]) count(delivered bool) {
+	if delivered {
+		s.delivered.Add(1)
+		s.broker.delivered.Add(1)
+	} else {
+		s.dropped.Add(1)
+		s.broker.dropped.Add(1)
+	}
+}
@@ -0,0 +1,190 @@
+package patterns
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/go-class/11/leaktest"
+)
+
+func TestBrokerTopics(t *testing.T) {
+	b := NewBroker[string]()
+	a1, _ := b.Subscribe("a", SubscriberOptions{})
+	a2, _ := b.Subscribe("a", SubscriberOptions{})
+	other, _ := b.Subscribe("b", SubscriberOptions{})
+
+	if n, err := b.Publish("a", "hello"); n != 2 || err != nil {
+		t.Fatalf("Publish=%d, %v want 2 subscribers", n, err)
+	}
+	if n, _ := b.Publish("nobody", "x"); n != 0 {
+		t.Fatalf("topic without subscribers delivered to %d", n)
+	}
+	for _, s := range []*Subscription[string]{a1, a2} {
+		if v := <-s.C(); v != "hello" {
+			t.Fatalf("got %q", v)
+		}
+	}
+	select {
+	case v := <-other.C():
+		t.Fatalf("topic b received %q", v)
+	default:
+	}
+
+	a1.Unsubscribe()
+	a1.Unsubscribe()
+	if _, open := <-a1.C(); open || a1.Err() != nil {
+		t.Fatalf("Unsubscribe should close C with no error, err=%v", a1.Err())
+	}
+	if n, _ := b.Publish("a", "again"); n != 1 {
+		t.Fatalf("Publish after Unsubscribe delivered to %d", n)
+	}
+	if st := b.Stats(); st.Topics != 2 || st.Subscribers != 2 || st.Published != 3 || st.Delivered != 3 {
+		t.Fatalf("stats=%+v", st)
+	}
+}
+
+func TestBrokerSlowConsumerPolicies(t *testing.T) {
+	cases := []struct {
+		policy    SlowConsumerPolicy
+		want      []int
+		dropped   int64
+		err       error
+		connected bool
+	}{
+		{DropOldest, []int{3, 4}, 3, nil, true},
+		{DropNewest, []int{0, 1}, 3, nil, true},
+		{BlockWithTimeout, []int{0, 1}, 3, nil, true},
+		{Disconnect, []int{0, 1}, 0, ErrSlowConsumer, false},
+	}
+	for _, tc := range cases {
+		b := NewBroker[int]()
+		s, _ := b.Subscribe("t", SubscriberOptions{Buffer: 2, Policy: tc.policy, BlockTimeout: time.Millisecond})
+		for i := 0; i < 5; i++ {
+			b.Publish("t", i)
+		}
+		if tc.connected {
+			b.Close()
+		}
+		if got := collect(s.C()); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("policy %d: got %v want %v", tc.policy, got, tc.want)
+		}
+		if s.Dropped() != tc.dropped {
+			t.Fatalf("policy %d: dropped %d want %d", tc.policy, s.Dropped(), tc.dropped)
+		}
+		if !tc.connected && !errors.Is(s.Err(), tc.err) {
+			t.Fatalf("policy %d: Err()=%v want %v", tc.policy, s.Err(), tc.err)
+		}
+		if st := b.Stats(); !tc.connected && (st.Disconnected != 1 || st.Subscribers != 0) {
+			t.Fatalf("policy %d: stats=%+v", tc.policy, st)
+		}
+	}
+}
+
+func TestBrokerBlockWaitsForReader(t *testing.T) {
+	leaktest.Check(t)
+	b := NewBroker[int]()
+	s, _ := b.Subscribe("t", SubscriberOptions{Buffer: 1, Policy: BlockWithTimeout, BlockTimeout: time.Second})
+	b.Publish("t", 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-s.C()
+	}()
+	if n, _ := b.Publish("t", 2); n != 1 {
+		t.Fatalf("blocked publish was dropped although the reader caught up")
+	}
+
+	b.Close()
+}
+
+func TestBrokerClose(t *testing.T) {
+	leaktest.Check(t)
+	b := NewBroker[int]()
+	var subs []*Subscription[int]
+	for _, topic := range []string{"a", "b", "b"} {
+		s, _ := b.Subscribe(topic, SubscriberOptions{Buffer: 100})
+		subs = append(subs, s)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				if _, err := b.Publish([]string{"a", "b"}[j%2], j); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(5 * time.Millisecond)
+	b.Close()
+	wg.Wait()
+
+	for _, s := range subs {
+		collect(s.C()) // every channel must be closed
+		if !errors.Is(s.Err(), ErrBrokerClosed) {
+			t.Fatalf("Err()=%v want ErrBrokerClosed", s.Err())
+		}
+		s.Unsubscribe()
+	}
+	if _, err := b.Subscribe("a", SubscriberOptions{}); !errors.Is(err, ErrBrokerClosed) {
+		t.Fatalf("Subscribe after Close: %v", err)
+	}
+	if _, err := b.Publish("a", 0); !errors.Is(err, ErrBrokerClosed) {
+		t.Fatalf("Publish after Close: %v", err)
+	}
+	if st := b.Stats(); st.Subscribers != 0 {
+		t.Fatalf("stats=%+v", st)
+	}
+}
+
+func TestBrokerTeardownDoesNotWaitForBlockedPublish(t *testing.T) {
+	leaktest.Check(t)
+	for _, teardown := range []string{"unsubscribe", "close"} {
+		b := NewBroker[int]()
+		s, _ := b.Subscribe("t", SubscriberOptions{Buffer: 1, Policy: BlockWithTimeout, BlockTimeout: 10 * time.Second})
+		b.Publish("t", 1)
+		published := make(chan struct{})
+		go func() {
+			b.Publish("t", 2) // blocks: nobody reads
+			close(published)
+		}()
+		waitLocked(t, s)
+
+		start := time.Now()
+		if teardown == "unsubscribe" {
+			s.Unsubscribe()
+		} else {
+			b.Close()
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("%s took %v while a publish was blocked", teardown, d)
+		}
+		select {
+		case <-published:
+		case <-time.After(time.Second):
+			t.Fatalf("%s did not release the blocked publish", teardown)
+		}
+		if got := collect(s.C()); len(got) != 1 || got[0] != 1 {
+			t.Fatalf("%s: buffered events=%v want [1]", teardown, got)
+		}
+		b.Close()
+	}
+}
+
+// waitLocked waits until a publish holds s's delivery lock.
+func waitLocked[T any](t *testing.T, s *Subscription[T]) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for s.mu.TryLock() {
+		s.mu.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatalf("publish never started blocking")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
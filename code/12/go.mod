@@ -1,3 +1,7 @@
 module example.com/go-class/12
 
 go 1.22.0
+
+require example.com/go-class/11 v0.0.0
+
+replace example.com/go-class/11 => ../11
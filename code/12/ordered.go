@@ -0,0 +1,93 @@
+package patterns
+
+import (
+	"context"
+	"sync"
+)
+
+// seqItem tags a value with its input position.
+type seqItem[T any] struct {
+	seq int
+	v   T
+}
+
+// orderedMap runs fn on opts.Workers goroutines and emits results in input
+// order. A dispatcher numbers the inputs and takes a slot per item, workers
+// compute out of order, and a collector holds early results until their
+// turn, freeing the slot once an item is emitted. With ReorderBuffer slots,
+// one slow item stalls intake after that many items instead of letting
+// finished results pile up.
+func orderedMap[In, Out any](p *Pipeline, in <-chan In, fn func(context.Context, In) (Out, error), opts StageOptions, out chan Out) {
+	workers := opts.workers()
+	window := opts.ReorderBuffer
+	if window <= 0 {
+		window = 2 * workers
+	}
+	window = max(window, workers)
+	slots := make(chan struct{}, window)
+	jobs := make(chan seqItem[In])
+	results := make(chan seqItem[Out], window)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(jobs)
+		for seq := 0; ; seq++ {
+			v, ok := recv(p.ctx, in)
+			if !ok || !send(p.ctx, slots, struct{}{}) || !send(p.ctx, jobs, seqItem[In]{seq, v}) {
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			defer wg.Done()
+			for {
+				j, ok := recv(p.ctx, jobs)
+				if !ok {
+					return
+				}
+				r, ok := call(p, fn, j.v)
+				if !ok || !send(p.ctx, results, seqItem[Out]{j.seq, r}) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(out)
+		pending := make(map[int]Out, window)
+		next := 0
+		for {
+			r, ok := recv(p.ctx, results)
+			if !ok {
+				return
+			}
+			pending[r.seq] = r.v
+			for {
+				v, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				if !send(p.ctx, out, v) {
+					return
+				}
+				next++
+				<-slots
+			}
+		}
+	}()
+}
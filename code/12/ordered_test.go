@@ -0,0 +1,113 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/go-class/11/leaktest"
+)
+
+func sequence(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+func TestOrderedMap(t *testing.T) {
+	leaktest.Check(t)
+	p := NewPipeline(context.Background())
+	jitter := func(_ context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+		return v * 2, nil
+	}
+	got := collect(Map(p, From(p, sequence(200), StageOptions{}), jitter, StageOptions{Workers: 8, Ordered: true}))
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 200 {
+		t.Fatalf("got %d results want 200", len(got))
+	}
+	for i, v := range got {
+		if v != i*2 {
+			t.Fatalf("got[%d]=%d want %d: output out of order", i, v, i*2)
+		}
+	}
+}
+
+func TestOrderedMapBoundsReorderBuffer(t *testing.T) {
+	leaktest.Check(t)
+	release := make(chan struct{})
+	var started atomic.Int32
+	fn := func(_ context.Context, v int) (int, error) {
+		started.Add(1)
+		if v == 0 {
+			<-release // the first item is slow; everything behind it must wait
+		}
+		return v, nil
+	}
+	p := NewPipeline(context.Background())
+	out := Map(p, From(p, sequence(100), StageOptions{}), fn, StageOptions{Workers: 4, Ordered: true, ReorderBuffer: 10})
+
+	time.Sleep(50 * time.Millisecond)
+	if n := started.Load(); n != 10 {
+		t.Fatalf("%d items started while item 0 was stuck, want exactly the 10-item buffer", n)
+	}
+	close(release)
+	got := collect(out)
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("got[%d]=%d", i, v)
+		}
+	}
+}
+
+func TestUnorderedMapEmitsWhenReady(t *testing.T) {
+	leaktest.Check(t)
+	release := make(chan struct{})
+	fn := func(_ context.Context, v int) (int, error) {
+		if v == 0 {
+			<-release
+		}
+		return v, nil
+	}
+	p := NewPipeline(context.Background())
+	out := Map(p, From(p, sequence(5), StageOptions{}), fn, StageOptions{Workers: 2})
+	if first := <-out; first == 0 {
+		t.Fatalf("slow item 0 came out first on the unordered path")
+	}
+	close(release)
+	collect(out)
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrderedMapError(t *testing.T) {
+	leaktest.Check(t)
+	boom := errors.New("boom")
+	fn := func(_ context.Context, v int) (int, error) {
+		if v == 30 {
+			return 0, boom
+		}
+		return v, nil
+	}
+	p := NewPipeline(context.Background())
+	got := collect(Map(p, counter(p.Context()), fn, StageOptions{Workers: 4, Ordered: true}))
+	if err := p.Wait(); !errors.Is(err, boom) {
+		t.Fatalf("Wait()=%v want boom", err)
+	}
+	for i, v := range got {
+		if v != i || v >= 30 {
+			t.Fatalf("got[%d]=%d: results after the failure or out of order", i, v)
+		}
+	}
+}
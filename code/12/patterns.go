@@ -5,10 +5,14 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	concurrency "example.com/go-class/11"
 )
 
 // PipelineDoubleThenAdd builds a two-stage pipeline:
 // stage1: x*2, stage2: x+1. It returns the output channel.
+// Its goroutines only exit once in is closed and the output drained; see
+// Pipeline and Map for cancellable stages.
 func PipelineDoubleThenAdd(in <-chan int) <-chan int {
 	stage1 := func(input <-chan int) <-chan int {
 		out := make(chan int)
@@ -33,10 +37,13 @@ func PipelineDoubleThenAdd(in <-chan int) <-chan int {
 	return stage2(stage1(in))
 }
 
-// FanOutSquare starts workerCount goroutines to square numbers from in and merges them into one output channel.
-// It closes the output when all workers finish or ctx is done.
-func FanOutSquare(ctx context.Context, in <-chan int, workerCount int) <-chan int {
-	out := make(chan int)
+// FanOut starts workerCount goroutines that apply fn to values from in and
+// merges their results into one channel, closed when in is drained or ctx is
+// done. Each call goes through concurrency.RunJob with opts, so a job that
+// panics, fails or times out yields a Result with Err set and the other
+// workers carry on.
+func FanOut[In, Out any](ctx context.Context, in <-chan In, workerCount int, fn func(context.Context, In) (Out, error), opts concurrency.JobOptions) <-chan concurrency.Result[Out] {
+	out := make(chan concurrency.Result[Out])
 	if workerCount <= 0 {
 		close(out)
 		return out
@@ -46,10 +53,11 @@ func FanOutSquare(ctx context.Context, in <-chan int, workerCount int) <-chan in
 	worker := func() {
 		defer wg.Done()
 		for v := range in {
+			value, err := concurrency.RunJob(ctx, opts, fn, v)
 			select {
 			case <-ctx.Done():
 				return
-			case out <- v * v:
+			case out <- concurrency.Result[Out]{Value: value, Err: err}:
 			}
 		}
 	}
@@ -66,6 +74,29 @@ func FanOutSquare(ctx context.Context, in <-chan int, workerCount int) <-chan in
 	return out
 }
 
+// FanOutSquare starts workerCount goroutines to square numbers from in and merges them into one output channel.
+// It closes the output when all workers finish or ctx is done. Results come in
+// completion order; Map with StageOptions.Ordered keeps input order.
+func FanOutSquare(ctx context.Context, in <-chan int, workerCount int) <-chan int {
+	square := func(_ context.Context, v int) (int, error) { return v * v, nil }
+	results := FanOut(ctx, in, workerCount, square, concurrency.JobOptions{})
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for r := range results {
+			if r.Err != nil {
+				continue // squaring cannot fail; only a recovered panic lands here
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- r.Value:
+			}
+		}
+	}()
+	return out
+}
+
 // SendWithTimeout tries to send v into ch, respecting ctx and timeout.
 // It returns ctx.Err(), deadline exceeded, or nil on success.
 func SendWithTimeout(ctx context.Context, ch chan<- int, v int, timeout time.Duration) error {
@@ -6,6 +6,9 @@ import (
 	"sort"
 	"testing"
 	"time"
+
+	concurrency "example.com/go-class/11"
+	"example.com/go-class/11/leaktest"
 )
 
 func TestPipelineDoubleThenAdd(t *testing.T) {
@@ -31,6 +34,7 @@ func TestPipelineDoubleThenAdd(t *testing.T) {
 }
 
 func TestFanOutSquare(t *testing.T) {
+	leaktest.Check(t)
 	ctx := context.Background()
 	in := make(chan int, 4)
 	for _, v := range []int{1, 2, 3, 4} {
@@ -84,3 +88,52 @@ func TestSendWithTimeoutContextCancel(t *testing.T) {
 		t.Fatalf("expected ctx error")
 	}
 }
+
+func TestFanOutIsolatesPanics(t *testing.T) {
+	leaktest.Check(t)
+	in := make(chan int, 5)
+	for _, v := range []int{1, 2, 3, 4, 5} {
+		in <- v
+	}
+	close(in)
+	fn := func(ctx context.Context, v int) (int, error) {
+		if v == 3 {
+			panic("three")
+		}
+		return v * 10, nil
+	}
+
+	var got []int
+	var panics int
+	for r := range FanOut(context.Background(), in, 2, fn, concurrency.JobOptions{}) {
+		var perr *concurrency.PanicError
+		switch {
+		case errors.As(r.Err, &perr):
+			panics++
+		case r.Err != nil:
+			t.Fatalf("unexpected error: %v", r.Err)
+		default:
+			got = append(got, r.Value)
+		}
+	}
+	sort.Ints(got)
+	if panics != 1 || len(got) != 4 || got[0] != 10 || got[3] != 50 {
+		t.Fatalf("got %v with %d panics", got, panics)
+	}
+}
+
+func TestFanOutJobTimeout(t *testing.T) {
+	leaktest.Check(t)
+	in := make(chan int, 1)
+	in <- 1
+	close(in)
+	stuck := func(ctx context.Context, v int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	for r := range FanOut(context.Background(), in, 1, stuck, concurrency.JobOptions{Timeout: 10 * time.Millisecond}) {
+		if !errors.Is(r.Err, context.DeadlineExceeded) {
+			t.Fatalf("err=%v want DeadlineExceeded", r.Err)
+		}
+	}
+}
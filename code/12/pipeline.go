@@ -0,0 +1,379 @@
+package patterns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	concurrency "example.com/go-class/11"
+)
+
+// Pipeline groups stages under one context. The first stage error cancels
+// it, so every stage upstream and downstream stops, and is delivered on the
+// single Errors channel. Cancelling the parent context stops all stages too,
+// which is how a consumer that stops reading releases the goroutines.
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	parent context.Context
+
+	once  sync.Once
+	first error
+	errs  chan error
+	wg    sync.WaitGroup
+}
+
+// NewPipeline returns a Pipeline bound to ctx.
+func NewPipeline(ctx context.Context) *Pipeline {
+	p := &Pipeline{parent: ctx, errs: make(chan error, 1)}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	return p
+}
+
+// Context is done once the pipeline failed or its parent ended.
+func (p *Pipeline) Context() context.Context { return p.ctx }
+
+// Errors delivers the first stage error and is then closed; if no stage
+// fails, Wait closes it.
+func (p *Pipeline) Errors() <-chan error { return p.errs }
+
+// Stop cancels every stage without reporting an error.
+func (p *Pipeline) Stop() { p.cancel() }
+
+// Wait blocks until every stage has exited and returns the first stage
+// error, else the parent context's error, else nil.
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	p.once.Do(func() { close(p.errs) })
+	p.cancel()
+	if p.first != nil {
+		return p.first
+	}
+	return p.parent.Err()
+}
+
+// fail records err as the pipeline's error. Once the pipeline is done, a
+// stage returning an error is just winding down, usually with ctx.Err()
+// after Stop or a parent cancel, so nothing is reported.
+func (p *Pipeline) fail(err error) {
+	if p.ctx.Err() != nil {
+		return
+	}
+	p.once.Do(func() {
+		p.first = err
+		p.errs <- err
+		close(p.errs)
+		p.cancel()
+	})
+}
+
+// StageOptions tunes one stage. The zero value is one worker and an
+// unbuffered output channel.
+type StageOptions struct {
+	// Buffer is the output channel capacity.
+	Buffer int
+	// Workers runs the stage function on that many goroutines; output order
+	// then follows completion order unless Ordered is set. Stages that keep
+	// state across items (Batch, Window, Take) ignore it.
+	Workers int
+	// Ordered makes a parallel Map emit results in input order.
+	Ordered bool
+	// ReorderBuffer caps how many items an Ordered Map holds at once, running
+	// or finished but waiting for an earlier slow one; when it is full, no new
+	// input is read. Zero means twice Workers.
+	ReorderBuffer int
+}
+
+func (o StageOptions) workers() int { return max(o.Workers, 1) }
+
+// stage starts workers goroutines running body and closes out once they
+// have all returned.
+func stage[T any](p *Pipeline, out chan T, workers int, body func()) {
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			defer wg.Done()
+			body()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+}
+
+// recv takes the next value from in, giving up when the pipeline is done.
+func recv[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	case v, ok := <-in:
+		return v, ok
+	}
+}
+
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case out <- v:
+		return true
+	}
+}
+
+// call runs fn through concurrency.RunJob so a panic fails the pipeline
+// instead of the process.
+func call[In, Out any](p *Pipeline, fn func(context.Context, In) (Out, error), v In) (Out, bool) {
+	out, err := concurrency.RunJob(p.ctx, concurrency.JobOptions{}, fn, v)
+	if err != nil {
+		p.fail(err)
+		return out, false
+	}
+	return out, true
+}
+
+// From emits values, then closes.
+func From[T any](p *Pipeline, values []T, opts StageOptions) <-chan T {
+	out := make(chan T, opts.Buffer)
+	stage(p, out, 1, func() {
+		for _, v := range values {
+			if !send(p.ctx, out, v) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// Map emits fn(v) for every v from in, in input order if opts.Ordered is set
+// and otherwise as soon as each result is ready.
+func Map[In, Out any](p *Pipeline, in <-chan In, fn func(context.Context, In) (Out, error), opts StageOptions) <-chan Out {
+	out := make(chan Out, opts.Buffer)
+	if opts.Ordered && opts.workers() > 1 {
+		orderedMap(p, in, fn, opts, out)
+		return out
+	}
+	stage(p, out, opts.workers(), func() {
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+			r, ok := call(p, fn, v)
+			if !ok || !send(p.ctx, out, r) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// Filter emits the values from in for which keep returns true.
+func Filter[T any](p *Pipeline, in <-chan T, keep func(context.Context, T) (bool, error), opts StageOptions) <-chan T {
+	out := make(chan T, opts.Buffer)
+	stage(p, out, opts.workers(), func() {
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+			pass, ok := call(p, keep, v)
+			if !ok || pass && !send(p.ctx, out, v) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// FlatMap emits every element of fn(v) for every v from in.
+func FlatMap[In, Out any](p *Pipeline, in <-chan In, fn func(context.Context, In) ([]Out, error), opts StageOptions) <-chan Out {
+	out := make(chan Out, opts.Buffer)
+	stage(p, out, opts.workers(), func() {
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+			rs, ok := call(p, fn, v)
+			if !ok {
+				return
+			}
+			for _, r := range rs {
+				if !send(p.ctx, out, r) {
+					return
+				}
+			}
+		}
+	})
+	return out
+}
+
+// Batch groups values into slices of size, emitting a shorter one when
+// maxWait has passed since its first value (zero waits for a full batch)
+// and when in closes.
+func Batch[T any](p *Pipeline, in <-chan T, size int, maxWait time.Duration, opts StageOptions) <-chan []T {
+	size = max(size, 1)
+	out := make(chan []T, opts.Buffer)
+	stage(p, out, 1, func() {
+		var (
+			batch []T
+			timer *time.Timer
+			flush <-chan time.Time
+		)
+		emit := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, flush = nil, nil
+			}
+			b := batch
+			batch = nil
+			return len(b) == 0 || send(p.ctx, out, b)
+		}
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-flush:
+				timer, flush = nil, nil
+				if !emit() {
+					return
+				}
+			case v, ok := <-in:
+				if !ok {
+					emit()
+					return
+				}
+				batch = append(batch, v)
+				if len(batch) == 1 && maxWait > 0 {
+					timer = time.NewTimer(maxWait)
+					flush = timer.C
+				}
+				if len(batch) == size && !emit() {
+					return
+				}
+			}
+		}
+	})
+	return out
+}
+
+// Window emits sliding windows of size consecutive values, starting a new
+// window every step values. A trailing partial window is not emitted.
+func Window[T any](p *Pipeline, in <-chan T, size, step int, opts StageOptions) <-chan []T {
+	size, step = max(size, 1), max(step, 1)
+	out := make(chan []T, opts.Buffer)
+	stage(p, out, 1, func() {
+		var buf []T
+		skip := 0 // values to drop before the next window starts, when step > size
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			buf = append(buf, v)
+			if len(buf) < size {
+				continue
+			}
+			if !send(p.ctx, out, append([]T(nil), buf...)) {
+				return
+			}
+			if step < size {
+				buf = append(buf[:0], buf[step:]...)
+			} else {
+				buf, skip = buf[:0], step-size
+			}
+		}
+	})
+	return out
+}
+
+// Tee copies every value from in to n outputs. Each value goes to all
+// outputs before the next is read, so the slowest reader sets the pace;
+// give the outputs a Buffer to absorb bursts.
+func Tee[T any](p *Pipeline, in <-chan T, n int, opts StageOptions) []<-chan T {
+	outs := make([]chan T, max(n, 1))
+	views := make([]<-chan T, len(outs))
+	for i := range outs {
+		outs[i] = make(chan T, opts.Buffer)
+		views[i] = outs[i]
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for {
+			v, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+			for _, out := range outs {
+				if !send(p.ctx, out, v) {
+					return
+				}
+			}
+		}
+	}()
+	return views
+}
+
+// Merge interleaves the values of all ins into one channel.
+func Merge[T any](p *Pipeline, opts StageOptions, ins ...<-chan T) <-chan T {
+	out := make(chan T, opts.Buffer)
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	p.wg.Add(len(ins))
+	for _, in := range ins {
+		go func(in <-chan T) {
+			defer p.wg.Done()
+			defer wg.Done()
+			for {
+				v, ok := recv(p.ctx, in)
+				if !ok || !send(p.ctx, out, v) {
+					return
+				}
+			}
+		}(in)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Take emits the first n values from in and then closes its output. It keeps
+// draining in so upstream stages can finish; with an endless source, Stop
+// the pipeline once done.
+func Take[T any](p *Pipeline, in <-chan T, n int, opts StageOptions) <-chan T {
+	out := make(chan T, opts.Buffer)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for i := 0; i < n; i++ {
+			v, ok := recv(p.ctx, in)
+			if !ok || !send(p.ctx, out, v) {
+				break
+			}
+		}
+		close(out)
+		for {
+			if _, ok := recv(p.ctx, in); !ok {
+				return
+			}
+		}
+	}()
+	return out
+}
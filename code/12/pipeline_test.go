@@ -0,0 +1,219 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	concurrency "example.com/go-class/11"
+	"example.com/go-class/11/leaktest"
+)
+
+func collect[T any](ch <-chan T) []T {
+	var out []T
+	for v := range ch {
+		out = append(out, v)
+	}
+	return out
+}
+
+// counter emits 0, 1, 2, ... until ctx is done.
+func counter(ctx context.Context) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- i:
+			}
+		}
+	}()
+	return out
+}
+
+func TestPipelineStages(t *testing.T) {
+	leaktest.Check(t)
+	p := NewPipeline(context.Background())
+	nums := From(p, []int{1, 2, 3, 4, 5, 6}, StageOptions{})
+	even := Filter(p, nums, func(_ context.Context, v int) (bool, error) { return v%2 == 0, nil }, StageOptions{})
+	squares := Map(p, even, func(_ context.Context, v int) (int, error) { return v * v, nil }, StageOptions{Workers: 3, Buffer: 2})
+	digits := FlatMap(p, squares, func(_ context.Context, v int) ([]string, error) {
+		return strings.Split(fmt.Sprint(v), ""), nil
+	}, StageOptions{})
+
+	got := collect(digits)
+	if err := p.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Strings(got)
+	// 4, 16, 36
+	if want := []string{"1", "3", "4", "6", "6"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if _, open := <-p.Errors(); open {
+		t.Fatalf("Errors should be closed without a value")
+	}
+}
+
+func TestPipelineErrorCancelsUpstream(t *testing.T) {
+	leaktest.Check(t)
+	boom := errors.New("boom")
+	p := NewPipeline(context.Background())
+	mapped := Map(p, counter(p.Context()), func(_ context.Context, v int) (int, error) {
+		if v == 100 {
+			return 0, boom
+		}
+		return v, nil
+	}, StageOptions{Workers: 4})
+	out := Map(p, mapped, func(_ context.Context, v int) (int, error) { return v, nil }, StageOptions{})
+
+	for range out {
+	}
+	if err := <-p.Errors(); !errors.Is(err, boom) {
+		t.Fatalf("Errors()=%v want boom", err)
+	}
+	if err := p.Wait(); !errors.Is(err, boom) {
+		t.Fatalf("Wait()=%v want boom", err)
+	}
+}
+
+func TestPipelinePanicBecomesError(t *testing.T) {
+	leaktest.Check(t)
+	p := NewPipeline(context.Background())
+	out := Map(p, From(p, []int{1, 2, 3}, StageOptions{}), func(_ context.Context, v int) (int, error) {
+		if v == 2 {
+			panic("two")
+		}
+		return v, nil
+	}, StageOptions{})
+	collect(out)
+	var perr *concurrency.PanicError
+	if err := p.Wait(); !errors.As(err, &perr) {
+		t.Fatalf("Wait()=%v want *PanicError", err)
+	}
+}
+
+func TestPipelineConsumerStops(t *testing.T) {
+	leaktest.Check(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPipeline(ctx)
+	out := Map(p, counter(p.Context()), func(_ context.Context, v int) (int, error) { return v, nil }, StageOptions{Workers: 2})
+	<-out
+	<-out
+	cancel() // stop reading and release everything
+	if err := p.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait()=%v want context.Canceled", err)
+	}
+}
+
+func TestPipelineStopIsNotAnError(t *testing.T) {
+	leaktest.Check(t)
+	for _, opts := range []StageOptions{{}, {Workers: 3}, {Workers: 3, Ordered: true}} {
+		p := NewPipeline(context.Background())
+		started := make(chan struct{}, 1)
+		out := Map(p, counter(p.Context()), func(ctx context.Context, v int) (int, error) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}, opts)
+		<-started
+		p.Stop()
+		collect(out)
+		if err := p.Wait(); err != nil {
+			t.Fatalf("%+v: Wait()=%v after Stop want nil", opts, err)
+		}
+		if err, ok := <-p.Errors(); ok {
+			t.Fatalf("%+v: Errors() delivered %v after Stop", opts, err)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	leaktest.Check(t)
+	p := NewPipeline(context.Background())
+	got := collect(Batch(p, From(p, []int{1, 2, 3, 4, 5}, StageOptions{}), 2, 0, StageOptions{}))
+	if want := [][]int{{1, 2}, {3, 4}, {5}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	// A partial batch goes out after maxWait even though in stays open.
+	in := make(chan int)
+	batches := Batch(p, in, 10, 10*time.Millisecond, StageOptions{})
+	in <- 1
+	in <- 2
+	select {
+	case b := <-batches:
+		if !reflect.DeepEqual(b, []int{1, 2}) {
+			t.Fatalf("timed batch=%v", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("partial batch was not flushed")
+	}
+	close(in)
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	leaktest.Check(t)
+	cases := []struct {
+		size, step int
+		want       [][]int
+	}{
+		{3, 1, [][]int{{1, 2, 3}, {2, 3, 4}, {3, 4, 5}}},
+		{2, 2, [][]int{{1, 2}, {3, 4}}},
+		{2, 3, [][]int{{1, 2}, {4, 5}}},
+		{6, 1, nil},
+	}
+	for _, tc := range cases {
+		p := NewPipeline(context.Background())
+		got := collect(Window(p, From(p, []int{1, 2, 3, 4, 5}, StageOptions{}), tc.size, tc.step, StageOptions{}))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("size %d step %d: got %v want %v", tc.size, tc.step, got, tc.want)
+		}
+		_ = p.Wait()
+	}
+}
+
+func TestTeeAndMerge(t *testing.T) {
+	leaktest.Check(t)
+	p := NewPipeline(context.Background())
+	outs := Tee(p, From(p, []int{1, 2, 3}, StageOptions{}), 2, StageOptions{Buffer: 3})
+	doubled := Map(p, outs[1], func(_ context.Context, v int) (int, error) { return v * 10, nil }, StageOptions{})
+	got := collect(Merge(p, StageOptions{}, outs[0], doubled))
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(got)
+	if want := []int{1, 2, 3, 10, 20, 30}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestTake(t *testing.T) {
+	leaktest.Check(t)
+	p := NewPipeline(context.Background())
+	got := collect(Take(p, From(p, []int{1, 2, 3, 4, 5}, StageOptions{}), 3, StageOptions{}))
+	if err := p.Wait(); err != nil || !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+
+	// An endless source keeps running until the pipeline is stopped.
+	p = NewPipeline(context.Background())
+	got = collect(Take(p, counter(p.Context()), 2, StageOptions{}))
+	p.Stop()
+	if err := p.Wait(); err != nil || !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+}
@@ -1,45 +0,0 @@
-package server
-
-import (
-	"log"
-	"net/http"
-	"time"
-)
-
-// LoggingMiddleware logs method, path, and duration.
-func LoggingMiddleware(logger *log.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			next.ServeHTTP(w, r)
-			if logger != nil {
-				logger.Printf("%s %s %v", r.Method, r.URL.Path, time.Since(start))
-			}
-		})
-	}
-}
-
-// RecoverMiddleware prevents panics from crashing the server.
-func RecoverMiddleware(logger *log.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			defer func() {
-				if rec := recover(); rec != nil {
-					if logger != nil {
-						logger.Printf("panic: %v", rec)
-					}
-					http.Error(w, "internal error", http.StatusInternalServerError)
-				}
-			}()
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
-// Chain applies middlewares from left to right.
-func Chain(h http.Handler, mws ...func(http.Handler) http.Handler) http.Handler {
-	for i := len(mws) - 1; i >= 0; i-- {
-		h = mws[i](h)
-	}
-	return h
-}
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"example.com/go-class/httpkit"
+	"example.com/go-class/httpkit/validate"
 )
 
 // Config controls server settings.
@@ -15,18 +18,48 @@ type Config struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
-	Logger       *log.Logger
+	Logger       *slog.Logger
+	TLS          httpkit.TLSOptions
 }
 
-// NewMux builds the mux with routes and middlewares.
-func NewMux(logger *log.Logger) http.Handler {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/hello", HelloHandler)
-	mux.HandleFunc("/echo", EchoHandler)
-	mux.HandleFunc("/healthz", HealthHandler)
+// Routes is the route table served by NewMux; this server has no auth.
+func Routes() []httpkit.Route {
+	return []httpkit.Route{
+		{
+			Method:  http.MethodGet,
+			Path:    "/hello",
+			Handler: http.HandlerFunc(HelloHandler),
+			Public:  true,
+			Summary: "greet ?name= (default gopher)",
+		},
+		{
+			Method:       http.MethodPost,
+			Path:         "/echo",
+			Handler:      http.HandlerFunc(EchoHandler),
+			Public:       true,
+			Summary:      "echo a JSON message",
+			MaxBodyBytes: 4 << 10,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/healthz",
+			Handler: http.HandlerFunc(HealthHandler),
+			Public:  true,
+			Summary: "liveness probe",
+		},
+	}
+}
 
-	// chain middlewares: recover then logging
-	return Chain(mux, RecoverMiddleware(logger), LoggingMiddleware(logger))
+// NewMux builds the mux with routes and middlewares.
+func NewMux(logger *slog.Logger) http.Handler {
+	// chain middlewares: request id first, then logging so it also sees recovered panics
+	return httpkit.Chain(httpkit.NewRouter(Routes()...),
+		httpkit.RequestIDMiddleware(),
+		httpkit.LoggingMiddleware(httpkit.ComponentLogger(logger, "http")),
+		httpkit.CompressMiddleware(httpkit.CompressOptions{}),
+		httpkit.RecoverMiddleware(httpkit.RecoverOptions{Logger: httpkit.ComponentLogger(logger, "recover")}),
+		httpkit.ClientCertMiddleware(),
+	)
 }
 
 // NewServer creates *http.Server configured with timeouts.
@@ -42,10 +75,6 @@ func NewServer(cfg Config) *http.Server {
 
 // HelloHandler responds with a greeting; defaults name to "gopher".
 func HelloHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	name := r.URL.Query().Get("name")
 	if name == "" {
 		name = "gopher"
@@ -56,33 +85,27 @@ func HelloHandler(w http.ResponseWriter, r *http.Request) {
 
 // EchoPayload is the expected request/response shape for /echo.
 type EchoPayload struct {
-	Message string `json:"message"`
+	Message string `json:"message" validate:"required,max=1000"`
 }
 
 // EchoHandler echos posted JSON payload.
 func EchoHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	defer r.Body.Close()
 
 	var payload EchoPayload
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&payload); err != nil {
-		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+	if err := httpkit.DecodeJSON(w, r, &payload); err != nil {
+		httpkit.WriteError(w, r, err)
 		return
 	}
-	if payload.Message == "" {
-		http.Error(w, "message is required", http.StatusBadRequest)
+	if err := validate.Struct(payload); err != nil {
+		httpkit.WriteError(w, r, err)
 		return
 	}
 
 	// Respect cancellation: if ctx is done before writing, abort.
 	select {
 	case <-r.Context().Done():
-		http.Error(w, r.Context().Err().Error(), http.StatusRequestTimeout)
+		httpkit.WriteError(w, r, r.Context().Err())
 		return
 	default:
 	}
@@ -94,10 +117,6 @@ func EchoHandler(w http.ResponseWriter, r *http.Request) {
 
 // HealthHandler returns 200 OK for probes.
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
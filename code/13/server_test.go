@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"log"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"example.com/go-class/httpkit"
 )
 
 func TestHelloHandler(t *testing.T) {
@@ -28,16 +31,32 @@ func TestHelloHandler(t *testing.T) {
 func TestHelloHandlerMethodNotAllowed(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/hello", nil)
 	rec := httptest.NewRecorder()
-	HelloHandler(rec, req)
+	NewMux(nil).ServeHTTP(rec, req)
 	if rec.Code != http.StatusMethodNotAllowed {
 		t.Fatalf("status=%d want 405", rec.Code)
 	}
+	if got := rec.Header().Get("Allow"); got != "GET, HEAD" {
+		t.Fatalf("Allow=%q want %q", got, "GET, HEAD")
+	}
+}
+
+func TestOptionsListsMethods(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/echo", nil)
+	rec := httptest.NewRecorder()
+	NewMux(nil).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status=%d want 204", rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "POST, OPTIONS" {
+		t.Fatalf("Allow=%q want %q", got, "POST, OPTIONS")
+	}
 }
 
 func TestEchoHandler(t *testing.T) {
 	payload := EchoPayload{Message: "hi"}
 	buf, _ := json.Marshal(payload)
 	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(buf))
+	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	EchoHandler(rec, req)
 	if rec.Code != http.StatusOK {
@@ -54,6 +73,7 @@ func TestEchoHandler(t *testing.T) {
 
 func TestEchoHandlerBadRequest(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	EchoHandler(rec, req)
 	if rec.Code != http.StatusBadRequest {
@@ -65,10 +85,36 @@ func TestEchoHandlerContextCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader([]byte(`{"message":"x"}`))).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	EchoHandler(rec, req)
-	if rec.Code != http.StatusRequestTimeout {
-		t.Fatalf("status=%d want 408-ish", rec.Code)
+	if rec.Code != httpkit.StatusClientClosedRequest {
+		t.Fatalf("status=%d want 499", rec.Code)
+	}
+}
+
+func TestEchoRejectsBadBodies(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"form encoded", "application/x-www-form-urlencoded", "message=hi", http.StatusUnsupportedMediaType},
+		{"unknown field", "application/json", `{"message":"hi","extra":1}`, http.StatusBadRequest},
+		{"trailing data", "application/json", `{"message":"hi"} {"message":"again"}`, http.StatusBadRequest},
+		{"over route limit", "application/json", `{"message":"` + strings.Repeat("a", 5<<10) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			NewMux(nil).ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status=%d want %d", rec.Code, tc.want)
+			}
+		})
 	}
 }
 
@@ -84,29 +130,34 @@ func TestHealthHandler(t *testing.T) {
 	}
 }
 
-func TestRecoverMiddleware(t *testing.T) {
-	panicHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
-		panic("boom")
-	})
-	h := Chain(panicHandler, RecoverMiddleware(log.Default()))
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	rec := httptest.NewRecorder()
-	h.ServeHTTP(rec, req)
-	if rec.Code != http.StatusInternalServerError {
-		t.Fatalf("status=%d want 500", rec.Code)
-	}
-}
-
 func TestNewServerConfig(t *testing.T) {
 	cfg := Config{
 		Addr:         ":0",
 		ReadTimeout:  time.Second,
 		WriteTimeout: 2 * time.Second,
 		IdleTimeout:  3 * time.Second,
-		Logger:       log.Default(),
+		Logger:       httpkit.NewLogger(httpkit.LogOptions{Output: io.Discard}),
 	}
 	srv := NewServer(cfg)
 	if srv.ReadTimeout != cfg.ReadTimeout || srv.WriteTimeout != cfg.WriteTimeout || srv.IdleTimeout != cfg.IdleTimeout {
 		t.Fatalf("server timeouts not applied")
 	}
 }
+
+func TestErrorBodyIncludesRequestID(t *testing.T) {
+	h := NewMux(nil)
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(httpkit.RequestIDHeader, "req-42")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d want 400", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != httpkit.ProblemContentType {
+		t.Fatalf("Content-Type=%q want %q", ct, httpkit.ProblemContentType)
+	}
+	if !strings.Contains(rec.Body.String(), `"request_id":"req-42"`) {
+		t.Fatalf("body=%q want request id", rec.Body.String())
+	}
+}
@@ -1,28 +1,53 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
 	"time"
 
 	server "example.com/go-class/14"
+	"example.com/go-class/httpkit"
 )
 
 func main() {
-	logger := log.New(os.Stdout, "[secure] ", log.LstdFlags|log.Lmicroseconds)
+	printRoutes := flag.Bool("routes", false, "print the route table as Markdown and exit")
+	flag.Parse()
+
+	opts, err := httpkit.ParseLogLevels(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		slog.Error("invalid LOG_LEVEL", "err", err)
+		os.Exit(1)
+	}
+	logger := httpkit.NewLogger(opts).With("service", "secure")
 	cfg := server.Config{
 		Addr:         ":8081",
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
 		Logger:       logger,
+		TLS:          httpkit.TLSOptionsFromEnv(logger),
 		JWTSecret:    "demo-token",
-		AllowOrigin:  "*",
+		CORS: httpkit.CORSOptions{
+			AllowedOrigins: []string{"*"},
+			ExposedHeaders: []string{httpkit.RequestIDHeader},
+			MaxAge:         10 * time.Minute,
+		},
+		Security: httpkit.DefaultSecurityOptions(),
+	}
+	if *printRoutes {
+		if err := httpkit.WriteRoutes(os.Stdout, server.Routes(cfg)); err != nil {
+			os.Exit(1)
+		}
+		return
 	}
 
 	srv := server.NewServer(cfg)
-	logger.Printf("listening on %s", cfg.Addr)
-	if err := srv.ListenAndServe(); err != nil && err.Error() != "http: Server closed" {
-		logger.Fatalf("server error: %v", err)
+	logger.Info("listening", "addr", cfg.Addr, "tls", cfg.TLS.Enabled(), "mtls", cfg.TLS.ClientCAFile != "")
+	if err := httpkit.ListenAndServe(srv, cfg.TLS); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("server error", "err", err)
+		os.Exit(1)
 	}
 }
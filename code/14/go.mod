@@ -2,4 +2,14 @@ module example.com/go-class/14
 
 go 1.22.0
 
-require github.com/golang-jwt/jwt/v5 v5.2.2
+require example.com/go-class/httpkit v0.0.0
+
+require (
+	example.com/go-class/10 v0.0.0 // indirect
+	github.com/andybalholm/brotli v1.2.0 // indirect
+	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
+)
+
+replace example.com/go-class/10 => ../10
+
+replace example.com/go-class/httpkit => ../httpkit
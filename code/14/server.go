@@ -3,12 +3,12 @@ package secure
 import (
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"example.com/go-class/httpkit"
+	"example.com/go-class/httpkit/validate"
 )
 
 // Config controls server settings.
@@ -17,26 +17,71 @@ type Config struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
-	Logger       *log.Logger
+	Logger       *slog.Logger
+	TLS          httpkit.TLSOptions
 	// Shared secret for signing JWT.
-	JWTSecret   string
-	AllowOrigin string
+	JWTSecret string
+	// CORS controls cross-origin access; no AllowedOrigins disables it.
+	CORS httpkit.CORSOptions
+	// Security is the response header policy; the zero value is minimal.
+	Security httpkit.SecurityOptions
+	// PanicSinks receive recovered panics in addition to the log.
+	PanicSinks []httpkit.PanicSink
+}
+
+// Routes is the route table served by NewMux; Public routes skip auth.
+func Routes(cfg Config) []httpkit.Route {
+	return []httpkit.Route{
+		{
+			Method:  http.MethodGet,
+			Path:    "/hello",
+			Handler: http.HandlerFunc(HelloHandler),
+			Summary: "greet ?name= (default gopher)",
+		},
+		{
+			Method:       http.MethodPost,
+			Path:         "/echo",
+			Handler:      http.HandlerFunc(EchoHandler),
+			Summary:      "validate and echo a JSON payload",
+			MaxBodyBytes: 4 << 10,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/healthz",
+			Handler: http.HandlerFunc(HealthHandler),
+			Public:  true,
+			Summary: "liveness probe",
+		},
+		{
+			Method:       http.MethodPost,
+			Path:         "/login",
+			Handler:      LoginHandler(cfg),
+			Public:       true,
+			Summary:      "exchange credentials for a JWT",
+			MaxBodyBytes: 1 << 10,
+		},
+	}
 }
 
 // NewMux wires routes and middlewares.
 func NewMux(cfg Config) http.Handler {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/hello", HelloHandler)
-	mux.HandleFunc("/echo", EchoHandler)
-	mux.HandleFunc("/healthz", HealthHandler)
-	mux.HandleFunc("/login", LoginHandler(cfg))
-
-	return Chain(
-		mux,
-		SecurityHeaders(cfg.AllowOrigin),
-		BearerAuthMiddleware(cfg.JWTSecret, []string{"/healthz", "/login"}),
-		RecoverMiddleware(cfg.Logger),
-		LoggingMiddleware(cfg.Logger),
+	router := httpkit.NewRouter(Routes(cfg)...)
+	return httpkit.Chain(
+		router,
+		httpkit.RequestIDMiddleware(),
+		httpkit.LoggingMiddleware(httpkit.ComponentLogger(cfg.Logger, "http")),
+		httpkit.CompressMiddleware(httpkit.CompressOptions{}),
+		httpkit.RecoverMiddleware(httpkit.RecoverOptions{
+			Logger: httpkit.ComponentLogger(cfg.Logger, "recover"),
+			Sinks:  cfg.PanicSinks,
+		}),
+		httpkit.ClientCertMiddleware(),
+		httpkit.CORSMiddleware(cfg.CORS),
+		httpkit.SecurityHeaders(cfg.Security),
+		httpkit.BearerAuthMiddleware(httpkit.AuthOptions{
+			Secret: cfg.JWTSecret,
+			Public: router.IsPublic,
+		}),
 	)
 }
 
@@ -51,12 +96,8 @@ func NewServer(cfg Config) *http.Server {
 	}
 }
 
-// HelloHandler responds with a greeting; requires GET.
+// HelloHandler responds with a greeting.
 func HelloHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	name := r.URL.Query().Get("name")
 	if name == "" {
 		name = "gopher"
@@ -68,33 +109,27 @@ func HelloHandler(w http.ResponseWriter, r *http.Request) {
 
 // EchoPayload is used for validation demo.
 type EchoPayload struct {
-	Message string `json:"message"`
-	Email   string `json:"email,omitempty"`
+	Message string `json:"message" validate:"required,max=1000"`
+	Email   string `json:"email,omitempty" validate:"email"`
 }
 
 // EchoHandler validates the payload and echoes it back.
 func EchoHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	defer r.Body.Close()
 
 	var payload EchoPayload
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&payload); err != nil {
-		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+	if err := httpkit.DecodeJSON(w, r, &payload); err != nil {
+		httpkit.WriteError(w, r, err)
 		return
 	}
-	if err := validatePayload(payload); err != nil {
-		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+	if err := validate.Struct(payload); err != nil {
+		httpkit.WriteError(w, r, err)
 		return
 	}
 
 	select {
 	case <-r.Context().Done():
-		http.Error(w, r.Context().Err().Error(), http.StatusRequestTimeout)
+		httpkit.WriteError(w, r, r.Context().Err())
 		return
 	default:
 	}
@@ -104,51 +139,36 @@ func EchoHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
-func validatePayload(p EchoPayload) error {
-	if strings.TrimSpace(p.Message) == "" {
-		return errors.New("message is required")
-	}
-	if p.Email != "" && !strings.Contains(p.Email, "@") {
-		return errors.New("email must contain @")
-	}
-	return nil
-}
-
 // HealthHandler returns 200 OK.
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
 }
 
+// LoginRequest is the body of POST /login.
+type LoginRequest struct {
+	Username string `json:"username" validate:"required,max=64"`
+	Password string `json:"password" validate:"required,max=128"`
+}
+
 // LoginHandler issues a bearer token after verifying credentials.
 func LoginHandler(cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
 		defer r.Body.Close()
-		var req struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		var req LoginRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			httpkit.WriteError(w, r, err)
 			return
 		}
-		if req.Username == "" || req.Password == "" {
-			http.Error(w, "username and password required", http.StatusBadRequest)
+		if err := validate.Struct(req); err != nil {
+			httpkit.WriteError(w, r, err)
 			return
 		}
 		const expectedUser = "alice"
 		const expectedPass = "123"
 		if req.Username != expectedUser || req.Password != expectedPass {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			httpkit.Error(w, r, "invalid username or password", http.StatusUnauthorized)
 			return
 		}
 		token := issueJWT(cfg.JWTSecret, req.Username)
@@ -161,13 +181,7 @@ func LoginHandler(cfg Config) http.HandlerFunc {
 var ErrServerClosed = errors.New("server closed")
 
 func issueJWT(secret, sub string) string {
-	claims := jwt.RegisteredClaims{
-		Subject:   sub,
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * time.Minute)),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(secret))
+	signed, err := httpkit.IssueJWT(secret, sub, 30*time.Minute)
 	if err != nil {
 		return ""
 	}
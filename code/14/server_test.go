@@ -8,12 +8,14 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"example.com/go-class/httpkit"
 )
 
 func newTestConfig() Config {
 	return Config{
-		JWTSecret:   "secret",
-		AllowOrigin: "https://example.com",
+		JWTSecret: "secret",
+		CORS:      httpkit.CORSOptions{AllowedOrigins: []string{"https://example.com"}},
 	}
 }
 
@@ -46,8 +48,9 @@ func TestHelloSuccess(t *testing.T) {
 func TestEchoValidation(t *testing.T) {
 	cfg := newTestConfig()
 	mux := NewMux(cfg)
-	body, _ := json.Marshal(EchoPayload{Message: ""})
+	body, _ := json.Marshal(EchoPayload{Message: "", Email: "not-an-email"})
 	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
 	token := issueJWT(cfg.JWTSecret, "alice")
 	req.Header.Set("Authorization", "Bearer "+token)
 	rec := httptest.NewRecorder()
@@ -55,6 +58,15 @@ func TestEchoValidation(t *testing.T) {
 	if rec.Code != http.StatusBadRequest {
 		t.Fatalf("status=%d want 400", rec.Code)
 	}
+	var resp struct {
+		Errors []struct{ Field, Reason string }
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Errors) != 2 || resp.Errors[0].Field != "message" || resp.Errors[1].Field != "email" {
+		t.Fatalf("errors=%+v want message and email", resp.Errors)
+	}
 }
 
 func TestEchoContextCancel(t *testing.T) {
@@ -64,12 +76,13 @@ func TestEchoContextCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
 	token := issueJWT(cfg.JWTSecret, "alice")
 	req.Header.Set("Authorization", "Bearer "+token)
 	rec := httptest.NewRecorder()
 	mux.ServeHTTP(rec, req)
-	if rec.Code != http.StatusRequestTimeout {
-		t.Fatalf("status=%d want timeout", rec.Code)
+	if rec.Code != httpkit.StatusClientClosedRequest {
+		t.Fatalf("status=%d want 499", rec.Code)
 	}
 }
 
@@ -87,6 +100,22 @@ func TestSecurityHeaders(t *testing.T) {
 	}
 }
 
+func TestCORSPreflightSkipsAuth(t *testing.T) {
+	mux := NewMux(newTestConfig())
+	req := httptest.NewRequest(http.MethodOptions, "/echo", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status=%d want 204", rec.Code)
+	}
+	if rec.Header().Get("Access-Control-Allow-Headers") != "authorization, content-type" {
+		t.Fatalf("allow-headers=%q", rec.Header().Get("Access-Control-Allow-Headers"))
+	}
+}
+
 func TestHealthzNoAuth(t *testing.T) {
 	mux := NewMux(newTestConfig())
 	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
@@ -102,6 +131,7 @@ func TestLogin(t *testing.T) {
 	mux := NewMux(cfg)
 	body, _ := json.Marshal(map[string]string{"username": "alice", "password": "123"})
 	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	mux.ServeHTTP(rec, req)
 	if rec.Code != http.StatusOK {
@@ -111,8 +141,16 @@ func TestLogin(t *testing.T) {
 	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
-	if resp["token"] != cfg.Token {
-		t.Fatalf("token=%q want %q", resp["token"], cfg.Token)
+	if resp["token"] == "" {
+		t.Fatalf("login returned no token")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("Authorization", "Bearer "+resp["token"])
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login token rejected: status=%d", rec.Code)
 	}
 }
 
@@ -121,6 +159,7 @@ func TestLoginBadCredentials(t *testing.T) {
 	mux := NewMux(cfg)
 	body, _ := json.Marshal(map[string]string{"username": "bad", "password": "bad"})
 	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	mux.ServeHTTP(rec, req)
 	if rec.Code != http.StatusUnauthorized {
@@ -1,15 +1,32 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	server "example.com/go-class/17"
+	"example.com/go-class/17/tracing"
+	"example.com/go-class/httpkit"
 )
 
 func main() {
-	logger := log.New(os.Stdout, "[chat] ", log.LstdFlags|log.Lmicroseconds)
+	printRoutes := flag.Bool("routes", false, "print the route table as Markdown and exit")
+	flag.Parse()
+
+	opts, err := httpkit.ParseLogLevels(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		slog.Error("invalid LOG_LEVEL", "err", err)
+		os.Exit(1)
+	}
+	logger := httpkit.NewLogger(opts).With("service", "chat")
 	modelID := os.Getenv("ARK_MODEL_ID")
 	if modelID == "" {
 		modelID = "deepseek-v3-250324"
@@ -20,14 +37,97 @@ func main() {
 		WriteTimeout: 0, // streaming
 		IdleTimeout:  60 * time.Second,
 		Logger:       logger,
+		TLS:          httpkit.TLSOptionsFromEnv(logger),
 		JWTSecret:    "demo-secret",
 		ModelID:      modelID,
-		AllowOrigin:  "*",
 		APIKey:       os.Getenv("ARK_API_KEY"),
+		BaseURL:      os.Getenv("ARK_BASE_URL"),
+		CORS: httpkit.CORSOptions{
+			AllowedOrigins: corsOrigins(),
+			ExposedHeaders: []string{httpkit.RequestIDHeader},
+			MaxAge:         10 * time.Minute,
+		},
+		Security: securityOptions(),
 	}
-	logger.Printf("listening on %s", cfg.Addr)
+	if *printRoutes {
+		if err := httpkit.WriteRoutes(os.Stdout, server.Routes(cfg)); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	cfg.Tracer = newTracer(logger)
+	logger.Info("listening", "addr", cfg.Addr, "tls", cfg.TLS.Enabled(), "mtls", cfg.TLS.ClientCAFile != "")
 	srv := server.NewServer(cfg)
-	if err := srv.ListenAndServe(); err != nil && err.Error() != "http: Server closed" {
-		logger.Fatalf("server error: %v", err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := httpkit.ListenAndServe(srv, cfg.TLS); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("server error", "err", err)
+		os.Exit(1)
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cfg.Tracer.Shutdown(flushCtx); err != nil {
+		logger.Error("trace flush failed", "err", err)
+	}
+}
+
+// corsOrigins reads CORS_ALLOWED_ORIGINS, a comma-separated list such as
+// "https://chat.example.com,https://*.example.dev"; unset allows any origin.
+func corsOrigins() []string {
+	v := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if v == "" {
+		return []string{"*"}
+	}
+	var origins []string
+	for _, o := range strings.Split(v, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// securityOptions is the default header policy with CSP reports sent to
+// /csp-report; CSP_REPORT_ONLY=1 reports violations without blocking them,
+// which helps when trying out a stricter policy.
+func securityOptions() httpkit.SecurityOptions {
+	opts := httpkit.DefaultSecurityOptions()
+	opts.CSP.ReportURI = "/csp-report"
+	opts.CSP.ReportOnly = os.Getenv("CSP_REPORT_ONLY") == "1"
+	return opts
+}
+
+// newTracer picks an exporter from the environment:
+// OTEL_EXPORTER_OTLP_ENDPOINT sends OTLP/HTTP, TRACE_FILE writes JSON lines
+// ("-" for stdout), and neither disables tracing.
+func newTracer(logger *slog.Logger) *tracing.Tracer {
+	var exp tracing.Exporter
+	switch {
+	case os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "":
+		exp = tracing.NewOTLPExporter(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "chatserver", nil)
+	case os.Getenv("TRACE_FILE") != "":
+		fileExp, err := tracing.NewFileExporter(os.Getenv("TRACE_FILE"))
+		if err != nil {
+			logger.Error("tracing disabled", "err", err)
+			return nil
+		}
+		exp = fileExp
+	default:
+		return nil
 	}
+	return tracing.New(tracing.Config{
+		Service:  "chatserver",
+		Exporter: exp,
+		OnError: func(err error) {
+			logger.Warn("trace export failed", "err", err)
+		},
+	})
 }
@@ -1,19 +1,58 @@
 package chatserver
 
 import (
+	"bytes"
 	"embed"
+	"html/template"
 	"io/fs"
 	"net/http"
+	"strings"
+	"sync"
+
+	"example.com/go-class/httpkit"
 )
 
+// Assets are precompressed to .br/.gz at build time; run go generate after
+// editing web/assets.
+//
+//go:generate go run example.com/go-class/httpkit/cmd/precompress web/assets
+
 //go:embed web/* web/assets/*
 var embeddedFrontend embed.FS
 
-// FrontendHandler serves the bundled static chat UI.
+// frontend is shared by the "/" and "/assets/" routes and the SPA fallback.
+var frontend = sync.OnceValue(FrontendHandler)
+
+// FrontendHandler serves the bundled static chat UI. Assets come from
+// httpkit.StaticFiles with content-hash ETags; index.html refers to them by
+// fingerprinted URL via the asset template func, so they can be cached
+// forever while index.html itself is revalidated on every load. index.html
+// is rendered per request so its tags carry the CSP nonce from
+// httpkit.SecurityHeaders, and any path outside /assets/ gets it too.
 func FrontendHandler() http.Handler {
-	content, err := fs.Sub(embeddedFrontend, "web")
+	assets, err := fs.Sub(embeddedFrontend, "web/assets")
+	if err != nil {
+		panic(err)
+	}
+	static, err := httpkit.NewStaticFiles(assets, "/assets/")
 	if err != nil {
 		panic(err)
 	}
-	return http.FileServer(http.FS(content))
+	index := template.Must(template.New("index.html").
+		Funcs(template.FuncMap{"asset": static.URL}).
+		ParseFS(embeddedFrontend, "web/index.html"))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/assets/") {
+			static.ServeHTTP(w, r)
+			return
+		}
+		var buf bytes.Buffer
+		if err := index.Execute(&buf, struct{ Nonce string }{httpkit.CSPNonce(r.Context())}); err != nil {
+			httpkit.WriteProblem(w, r, &httpkit.Problem{Status: http.StatusInternalServerError, Err: err})
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", httpkit.CacheNoCache)
+		_, _ = w.Write(buf.Bytes())
+	})
 }
@@ -0,0 +1,149 @@
+package chatserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"example.com/go-class/httpkit"
+	"github.com/andybalholm/brotli"
+)
+
+func testConfig() Config {
+	return Config{
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		JWTSecret: "test-secret",
+		ModelID:   "test-model",
+		Security:  httpkit.DefaultSecurityOptions(),
+	}
+}
+
+func get(t *testing.T, h http.Handler, path, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// go generate must be re-run after editing web/assets; this catches a
+// forgotten run before NewStaticFiles refuses to start.
+func TestEmbeddedVariantsMatchSources(t *testing.T) {
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		".gz": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		".br": func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	}
+	checked := 0
+	err := fs.WalkDir(embeddedFrontend, "web/assets", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		for ext, decode := range decoders {
+			variant, err := fs.ReadFile(embeddedFrontend, name+ext)
+			if err != nil {
+				continue
+			}
+			src, _ := fs.ReadFile(embeddedFrontend, name)
+			r, err := decode(bytes.NewReader(variant))
+			if err != nil {
+				t.Fatalf("%s%s: %v", name, ext, err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(got, src) {
+				t.Fatalf("%s%s is stale (%v); run go generate", name, ext, err)
+			}
+			checked++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked == 0 {
+		t.Fatalf("no precompressed assets embedded")
+	}
+}
+
+var assetURL = regexp.MustCompile(`/assets/(app|styles)\.[0-9a-f]{10}\.(js|css)`)
+
+func TestFrontendIndex(t *testing.T) {
+	h := NewMux(testConfig())
+	rec := get(t, h, "/", "text/html")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != httpkit.CacheNoCache {
+		t.Fatalf("index Cache-Control=%q want no-cache", got)
+	}
+	body := rec.Body.String()
+	if urls := assetURL.FindAllString(body, -1); len(urls) != 2 {
+		t.Fatalf("fingerprinted asset URLs in index: %q", urls)
+	}
+	if strings.Contains(body, `"/assets/app.js"`) || strings.Contains(body, `"/assets/styles.css"`) {
+		t.Fatalf("index still references an unfingerprinted asset")
+	}
+
+	m := regexp.MustCompile(`'nonce-([^']+)'`).FindStringSubmatch(rec.Header().Get("Content-Security-Policy"))
+	if m == nil {
+		t.Fatalf("CSP without nonce: %q", rec.Header().Get("Content-Security-Policy"))
+	}
+	// Compare what the browser sees after decoding attribute entities.
+	if n := strings.Count(html.UnescapeString(body), `nonce="`+m[1]+`"`); n < 2 {
+		t.Fatalf("index carries the CSP nonce on %d tags, want the stylesheet and script", n)
+	}
+	if again := get(t, h, "/", "text/html"); strings.Contains(again.Body.String(), m[1]) {
+		t.Fatalf("nonce reused across requests")
+	}
+}
+
+func TestFrontendAssetCaching(t *testing.T) {
+	h := NewMux(testConfig())
+	for _, url := range assetURL.FindAllString(get(t, h, "/", "text/html").Body.String(), -1) {
+		rec := get(t, h, url, "")
+		if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != httpkit.CacheImmutable {
+			t.Fatalf("%s: status=%d Cache-Control=%q want immutable", url, rec.Code, rec.Header().Get("Cache-Control"))
+		}
+		if rec.Header().Get("ETag") == "" {
+			t.Fatalf("%s: no ETag", url)
+		}
+	}
+	if rec := get(t, h, "/assets/app.js", ""); rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != httpkit.CacheNoCache {
+		t.Fatalf("plain asset path: status=%d Cache-Control=%q want no-cache", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestFrontendSPAFallback(t *testing.T) {
+	h := NewMux(testConfig())
+	cases := []struct {
+		name, path, accept string
+		want               int
+		wantType           string
+	}{
+		{"client route", "/conversations/42", "text/html,application/xhtml+xml", 200, "text/html; charset=utf-8"},
+		{"api client", "/conversations/42", "application/json", 404, httpkit.ProblemContentType},
+		{"missing file", "/favicon.ico", "text/html", 404, httpkit.ProblemContentType},
+		{"unknown asset", "/assets/missing.js", "text/html", 404, httpkit.ProblemContentType},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := get(t, h, tc.path, tc.accept)
+			if rec.Code != tc.want || rec.Header().Get("Content-Type") != tc.wantType {
+				t.Fatalf("status=%d type=%q want %d %q", rec.Code, rec.Header().Get("Content-Type"), tc.want, tc.wantType)
+			}
+			if tc.want == 200 && len(assetURL.FindAllString(rec.Body.String(), -1)) != 2 {
+				t.Fatalf("fallback did not render index.html")
+			}
+		})
+	}
+}
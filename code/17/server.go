@@ -5,25 +5,40 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"example.com/go-class/17/tracing"
+	"example.com/go-class/httpkit"
+	"example.com/go-class/httpkit/validate"
 	openai "github.com/sashabaranov/go-openai"
 )
 
+// ArkBaseURL is the default upstream chat API.
+const ArkBaseURL = "https://ark.cn-beijing.volces.com/api/v3"
+
 type Config struct {
 	Addr         string
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
-	Logger       *log.Logger
+	Logger       *slog.Logger
 	JWTSecret    string
 	ModelID      string
-	AllowOrigin  string
 	APIKey       string
+	// BaseURL is the OpenAI-compatible upstream API; empty means ArkBaseURL.
+	BaseURL string
+	TLS     httpkit.TLSOptions
+	// CORS controls cross-origin access; no AllowedOrigins disables it.
+	CORS httpkit.CORSOptions
+	// Security is the response header policy; the zero value is minimal.
+	Security httpkit.SecurityOptions
+	// Tracer records spans for middleware, handlers and upstream calls; nil disables tracing.
+	Tracer *tracing.Tracer
+	// PanicSinks receive recovered panics in addition to the log.
+	PanicSinks []httpkit.PanicSink
 }
 
 func NewServer(cfg Config) *http.Server {
@@ -36,45 +51,105 @@ func NewServer(cfg Config) *http.Server {
 	}
 }
 
+// Routes is the route table served by NewMux; Public routes skip auth.
+func Routes(cfg Config) []httpkit.Route {
+	ui := frontend()
+	return []httpkit.Route{
+		{
+			Method:       http.MethodPost,
+			Path:         "/login",
+			Handler:      traceHandler(cfg.Tracer, "handler.login", LoginHandler(cfg)),
+			Public:       true,
+			Summary:      "exchange credentials for a JWT",
+			MaxBodyBytes: 1 << 10,
+		},
+		{
+			Method:       http.MethodPost,
+			Path:         "/chat",
+			Handler:      traceHandler(cfg.Tracer, "handler.chat", ChatHandler(cfg)),
+			Summary:      "stream a model reply as SSE",
+			MaxBodyBytes: 64 << 10,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/healthz",
+			Handler: http.HandlerFunc(HealthHandler),
+			Public:  true,
+			Summary: "liveness probe",
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/{$}",
+			Handler: ui,
+			Public:  true,
+			Summary: "browser chat UI",
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/assets/",
+			Handler: ui,
+			Public:  true,
+			Summary: "static assets for the UI",
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/csp-report",
+			Handler: httpkit.CSPReportHandler(cfg.Logger),
+			Public:  true,
+			Summary: "collect CSP violation reports",
+		},
+	}
+}
+
 func NewMux(cfg Config) http.Handler {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/login", LoginHandler(cfg))
-	mux.HandleFunc("/chat", ChatHandler(cfg))
-	mux.HandleFunc("/healthz", HealthHandler)
-	mux.Handle("/", FrontendHandler())
-
-	return Chain(
-		mux,
-		SecurityHeaders(cfg.AllowOrigin),
-		BearerAuthMiddleware(cfg.JWTSecret, []string{"/login", "/healthz", "/", "/assets/*", "/favicon.ico"}),
-		RecoverMiddleware(cfg.Logger),
-		LoggingMiddleware(cfg.Logger),
+	router := httpkit.NewRouter(Routes(cfg)...)
+	router.NotFound = httpkit.SPAFallback(frontend())
+	return httpkit.Chain(
+		router,
+		httpkit.RequestIDMiddleware(),
+		TracingMiddleware(cfg.Tracer),
+		httpkit.LoggingMiddleware(httpkit.ComponentLogger(cfg.Logger, "http")),
+		httpkit.CompressMiddleware(httpkit.CompressOptions{}),
+		httpkit.RecoverMiddleware(httpkit.RecoverOptions{
+			Logger: httpkit.ComponentLogger(cfg.Logger, "recover"),
+			Sinks:  cfg.PanicSinks,
+		}),
+		httpkit.ClientCertMiddleware(),
+		traceMiddleware(cfg.Tracer, "cors", httpkit.CORSMiddleware(cfg.CORS)),
+		traceMiddleware(cfg.Tracer, "security_headers", httpkit.SecurityHeaders(cfg.Security)),
+		traceMiddleware(cfg.Tracer, "auth", httpkit.BearerAuthMiddleware(httpkit.AuthOptions{
+			Secret: cfg.JWTSecret,
+			Public: router.IsPublic,
+		})),
 	)
 }
 
+// LoginRequest is the body of POST /login.
+type LoginRequest struct {
+	Username string `json:"username" validate:"required,max=64"`
+	Password string `json:"password" validate:"required,max=128"`
+}
+
 // LoginHandler issues a JWT for fixed demo credentials.
 func LoginHandler(cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
 		defer r.Body.Close()
-		var req struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
+		var req LoginRequest
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			httpkit.WriteError(w, r, err)
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		if err := validate.Struct(req); err != nil {
+			httpkit.WriteError(w, r, err)
 			return
 		}
 		if req.Username != "alice" || req.Password != "123" {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			httpkit.Error(w, r, "invalid username or password", http.StatusUnauthorized)
 			return
 		}
 		token := issueJWT(cfg.JWTSecret, req.Username)
 		if token == "" {
-			http.Error(w, "failed to sign token", http.StatusInternalServerError)
+			httpkit.Error(w, r, "could not issue token", http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -84,31 +159,28 @@ func LoginHandler(cfg Config) http.HandlerFunc {
 
 // ChatRequest defines input for the SSE chat endpoint.
 type ChatRequest struct {
-	Message string `json:"message"`
-	Model   string `json:"model,omitempty"`
+	Message string `json:"message" validate:"required,max=8000"`
+	Model   string `json:"model,omitempty" validate:"max=128,regex=^[A-Za-z0-9._:/-]+$"`
 }
 
 // ChatHandler proxies to the Ark streaming API and returns SSE chunks.
 func ChatHandler(cfg Config) http.HandlerFunc {
+	logger := httpkit.ComponentLogger(cfg.Logger, "chat")
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
 		flusher, ok := w.(http.Flusher)
 		if !ok {
-			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			httpkit.Error(w, r, "streaming unsupported", http.StatusInternalServerError)
 			return
 		}
 		defer r.Body.Close()
 
 		var req ChatRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		if err := httpkit.DecodeJSON(w, r, &req); err != nil {
+			httpkit.WriteError(w, r, err)
 			return
 		}
-		if req.Message == "" {
-			http.Error(w, "message is required", http.StatusBadRequest)
+		if err := validate.Struct(req); err != nil {
+			httpkit.WriteError(w, r, err)
 			return
 		}
 
@@ -117,7 +189,11 @@ func ChatHandler(cfg Config) http.HandlerFunc {
 			apiKey = envKey
 		}
 		if apiKey == "" {
-			http.Error(w, "server not configured: missing ARK_API_KEY", http.StatusInternalServerError)
+			httpkit.WriteProblem(w, r, &httpkit.Problem{
+				Status: http.StatusServiceUnavailable,
+				Detail: "chat is not configured",
+				Err:    errors.New("missing ARK_API_KEY"),
+			})
 			return
 		}
 
@@ -129,15 +205,22 @@ func ChatHandler(cfg Config) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
 		defer cancel()
 
-		stream, err := newArkClient(apiKey).CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+		connectCtx, connect := cfg.Tracer.Start(ctx, "provider.connect", tracing.SpanKindInternal,
+			tracing.Attr{Key: "llm.model", Value: model})
+		stream, err := newArkClient(apiKey, cfg.BaseURL, cfg.Tracer).CreateChatCompletionStream(connectCtx, openai.ChatCompletionRequest{
 			Model: model,
 			Messages: []openai.ChatCompletionMessage{
 				{Role: openai.ChatMessageRoleSystem, Content: "你是人工智能助手"},
 				{Role: openai.ChatMessageRoleUser, Content: req.Message},
 			},
 		})
+		connect.SetError(err)
+		connect.End()
 		if err != nil {
-			http.Error(w, "chat stream error: "+err.Error(), http.StatusBadGateway)
+			if logger != nil {
+				logger.ErrorContext(r.Context(), "upstream connect failed", "model", model, "err", err)
+			}
+			httpkit.WriteProblem(w, r, &httpkit.Problem{Status: http.StatusBadGateway, Detail: "chat provider unavailable", Err: err})
 			return
 		}
 		defer stream.Close()
@@ -146,27 +229,45 @@ func ChatHandler(cfg Config) http.HandlerFunc {
 		w.Header().Set("Cache-Control", "no-cache")
 		w.Header().Set("Connection", "keep-alive")
 
+		meta, _ := json.Marshal(map[string]string{"request_id": httpkit.RequestID(r.Context()), "model": model})
+		writeSSE(w, "event: meta\ndata: "+string(meta)+"\n\n")
+		flusher.Flush()
+
+		ctx, streamSpan := cfg.Tracer.Start(ctx, "provider.stream", tracing.SpanKindInternal,
+			tracing.Attr{Key: "llm.model", Value: model})
+		defer streamSpan.End()
+		streamStart := time.Now()
+		chunks := 0
+
 		for {
 			select {
 			case <-ctx.Done():
-				writeSSE(w, "event: error\ndata: "+ctx.Err().Error()+"\n\n")
-				flusher.Flush()
+				streamSpan.SetError(ctx.Err())
+				httpkit.SSEError(w, r, httpkit.ProblemFor(ctx.Err()))
 				return
 			default:
 			}
 			resp, err := stream.Recv()
 			if errors.Is(err, io.EOF) {
+				streamSpan.AddEvent("stream_end", tracing.Attr{Key: "chunks", Value: chunks})
 				writeSSE(w, "event: done\ndata: [DONE]\n\n")
 				flusher.Flush()
 				return
 			}
 			if err != nil {
-				writeSSE(w, "event: error\ndata: "+err.Error()+"\n\n")
-				flusher.Flush()
+				streamSpan.SetError(err)
+				if logger != nil {
+					logger.ErrorContext(r.Context(), "upstream stream failed", "model", model, "err", err)
+				}
+				httpkit.SSEError(w, r, &httpkit.Problem{Status: http.StatusBadGateway, Detail: "chat stream interrupted", Err: err})
 				return
 			}
 			if len(resp.Choices) > 0 {
 				chunk := resp.Choices[0].Delta.Content
+				if chunks == 0 {
+					streamSpan.AddEvent("first_token", tracing.Attr{Key: "ttft_ms", Value: time.Since(streamStart).Milliseconds()})
+				}
+				chunks++
 				writeSSE(w, "data: "+chunk+"\n\n")
 				flusher.Flush()
 			}
@@ -189,21 +290,23 @@ func issueJWT(secret, sub string) string {
 	if secret == "" {
 		secret = "demo-secret"
 	}
-	claims := jwt.RegisteredClaims{
-		Subject:   sub,
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * time.Minute)),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(secret))
+	signed, err := httpkit.IssueJWT(secret, sub, 30*time.Minute)
 	if err != nil {
 		return ""
 	}
 	return signed
 }
 
-func newArkClient(apiKey string) *openai.Client {
+func newArkClient(apiKey, baseURL string, tracer *tracing.Tracer) *openai.Client {
 	cfg := openai.DefaultConfig(apiKey)
-	cfg.BaseURL = "https://ark.cn-beijing.volces.com/api/v3"
+	cfg.BaseURL = ArkBaseURL
+	if baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	transport := httpkit.RequestIDTransport(http.DefaultTransport)
+	if tracer != nil {
+		transport = tracing.Transport(tracer, transport)
+	}
+	cfg.HTTPClient = &http.Client{Transport: transport}
 	return openai.NewClientWithConfig(cfg)
 }
@@ -0,0 +1,263 @@
+package chatserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"example.com/go-class/17/tracing"
+	"example.com/go-class/httpkit"
+)
+
+const incomingTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// upstream is a stub OpenAI-compatible chat endpoint. It records the headers
+// of the last call and answers with the body that respond writes.
+type upstream struct {
+	*httptest.Server
+	mu     sync.Mutex
+	header http.Header
+}
+
+func newUpstream(t *testing.T, respond func(w http.ResponseWriter)) *upstream {
+	u := &upstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.header = r.Header.Clone()
+		u.mu.Unlock()
+		respond(w)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *upstream) lastHeader() http.Header {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.header
+}
+
+// streamChunks answers like a streaming chat completion.
+func streamChunks(chunks ...string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			data, _ := json.Marshal(map[string]any{
+				"id": "1", "object": "chat.completion.chunk",
+				"choices": []any{map[string]any{"index": 0, "delta": map[string]string{"content": c}}},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+type sseEvent struct{ Event, Data string }
+
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var cur sseEvent
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if cur != (sseEvent{}) {
+				events = append(events, cur)
+			}
+			cur = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			cur.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return events
+}
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (s *spanRecorder) Export(_ context.Context, spans []tracing.SpanData) error {
+	s.mu.Lock()
+	s.spans = append(s.spans, spans...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *spanRecorder) Shutdown(context.Context) error { return nil }
+
+// chat posts message to /chat as alice with the given request headers.
+func chat(t *testing.T, cfg Config, message string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(ChatRequest{Message: message})
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+issueJWT(cfg.JWTSecret, "alice"))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	NewMux(cfg).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestChatStreamsWithRequestID(t *testing.T) {
+	up := newUpstream(t, streamChunks("你好", "，世界"))
+	cfg := testConfig()
+	cfg.APIKey, cfg.BaseURL = "test-key", up.URL
+	cfg.Tracer = tracing.New(tracing.Config{Exporter: &spanRecorder{}})
+	defer cfg.Tracer.Shutdown(context.Background())
+
+	rec := chat(t, cfg, "hi", map[string]string{
+		"X-Request-ID":            "req-chat-1",
+		tracing.TraceparentHeader: incomingTraceparent,
+	})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status=%d type=%q body=%s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != "req-chat-1" {
+		t.Fatalf("response X-Request-ID=%q", got)
+	}
+
+	events := parseSSE(t, rec.Body.String())
+	if len(events) != 4 {
+		t.Fatalf("events=%+v want meta, two chunks and done", events)
+	}
+	var meta map[string]string
+	if events[0].Event != "meta" || json.Unmarshal([]byte(events[0].Data), &meta) != nil {
+		t.Fatalf("first event=%+v want meta", events[0])
+	}
+	if meta["request_id"] != "req-chat-1" || meta["model"] != "test-model" {
+		t.Fatalf("meta=%v", meta)
+	}
+	if events[1].Data != "你好" || events[2].Data != "，世界" || events[3].Event != "done" {
+		t.Fatalf("events=%+v", events)
+	}
+
+	h := up.lastHeader()
+	if got := h.Get("X-Request-ID"); got != "req-chat-1" {
+		t.Fatalf("upstream X-Request-ID=%q want the incoming id", got)
+	}
+	sc, ok := tracing.ParseTraceparent(h.Get(tracing.TraceparentHeader))
+	if !ok || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("upstream traceparent=%q want the incoming trace", h.Get(tracing.TraceparentHeader))
+	}
+	if h.Get("Authorization") != "Bearer test-key" {
+		t.Fatalf("upstream Authorization=%q", h.Get("Authorization"))
+	}
+}
+
+func TestChatGeneratesRequestID(t *testing.T) {
+	up := newUpstream(t, streamChunks("ok"))
+	cfg := testConfig()
+	cfg.APIKey, cfg.BaseURL = "test-key", up.URL
+
+	rec := chat(t, cfg, "hi", nil)
+	id := rec.Header().Get("X-Request-ID")
+	if id == "" {
+		t.Fatalf("no request id generated")
+	}
+	var meta map[string]string
+	if events := parseSSE(t, rec.Body.String()); len(events) == 0 || json.Unmarshal([]byte(events[0].Data), &meta) != nil {
+		t.Fatalf("no meta event in %q", rec.Body)
+	}
+	if meta["request_id"] != id || up.lastHeader().Get("X-Request-ID") != id {
+		t.Fatalf("meta=%v upstream=%q want %q everywhere", meta, up.lastHeader().Get("X-Request-ID"), id)
+	}
+}
+
+// upstreamSecret stands for provider detail that must stay in the logs.
+const upstreamSecret = "quota exhausted for account 4242"
+
+func TestChatUpstreamErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		respond func(http.ResponseWriter)
+		logMsg  string
+		check   func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name: "before the stream",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprintf(w, `{"error":{"message":%q,"type":"rate_limit"}}`, upstreamSecret)
+			},
+			logMsg: "upstream connect failed",
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if rec.Code != http.StatusBadGateway || rec.Header().Get("Content-Type") != httpkit.ProblemContentType {
+					t.Fatalf("status=%d type=%q want a 502 problem", rec.Code, rec.Header().Get("Content-Type"))
+				}
+				var p httpkit.Problem
+				if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || p.Detail != "chat provider unavailable" {
+					t.Fatalf("problem=%+v err=%v", p, err)
+				}
+			},
+		},
+		{
+			name: "mid-stream",
+			respond: func(w http.ResponseWriter) {
+				streamChunks("partial")(noDone{w})
+				fmt.Fprintf(w, "data: {\"error\":{\"message\":%q}}\n\n", upstreamSecret)
+			},
+			logMsg: "upstream stream failed",
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+					t.Fatalf("status=%d type=%q want the stream to have started", rec.Code, rec.Header().Get("Content-Type"))
+				}
+				events := parseSSE(t, rec.Body.String())
+				last := events[len(events)-1]
+				if len(events) != 3 || events[1].Data != "partial" || last.Event != "error" {
+					t.Fatalf("events=%+v want meta, the partial chunk and an error", events)
+				}
+				var p httpkit.Problem
+				if err := json.Unmarshal([]byte(last.Data), &p); err != nil || p.Status != http.StatusBadGateway || p.Detail != "chat stream interrupted" {
+					t.Fatalf("error event problem=%+v err=%v", p, err)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			up := newUpstream(t, tc.respond)
+			var logs bytes.Buffer
+			cfg := testConfig()
+			cfg.APIKey, cfg.BaseURL = "test-key", up.URL
+			cfg.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+			rec := chat(t, cfg, "hi", map[string]string{"X-Request-ID": "req-fail-1"})
+			tc.check(t, rec)
+			if strings.Contains(rec.Body.String(), upstreamSecret) {
+				t.Fatalf("upstream detail leaked to the client: %s", rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), "req-fail-1") {
+				t.Fatalf("error does not carry the request id: %s", rec.Body)
+			}
+			if !strings.Contains(logs.String(), tc.logMsg) || !strings.Contains(logs.String(), upstreamSecret) {
+				t.Fatalf("upstream error not logged with its detail:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+// noDone drops the [DONE] terminator so a stream can end in an error.
+type noDone struct{ http.ResponseWriter }
+
+func (w noDone) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("[DONE]")) {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
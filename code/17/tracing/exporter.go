@@ -0,0 +1,293 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WriterExporter writes one JSON object per span, e.g. to stdout or a file.
+type WriterExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewWriterExporter exports spans as JSON lines to w.
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// NewFileExporter appends spans as JSON lines to path; "-" means stdout.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	if path == "-" {
+		return NewWriterExporter(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file: %w", err)
+	}
+	return &WriterExporter{w: f, closer: f}, nil
+}
+
+type jsonSpan struct {
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Name       string         `json:"name"`
+	Kind       SpanKind       `json:"kind"`
+	Start      string         `json:"start"`
+	DurationMS float64        `json:"duration_ms"`
+	Attrs      map[string]any `json:"attrs,omitempty"`
+	Events     []jsonEvent    `json:"events,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type jsonEvent struct {
+	Name    string         `json:"name"`
+	AfterMS float64        `json:"after_ms"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// Export implements Exporter.
+func (e *WriterExporter) Export(_ context.Context, spans []SpanData) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range spans {
+		js := jsonSpan{
+			TraceID:    s.SpanContext.TraceID.String(),
+			SpanID:     s.SpanContext.SpanID.String(),
+			Name:       s.Name,
+			Kind:       s.Kind,
+			Start:      s.Start.UTC().Format("2006-01-02T15:04:05.000000Z"),
+			DurationMS: float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+			Attrs:      attrMap(s.Attrs),
+		}
+		if s.Parent.IsValid() {
+			js.ParentID = s.Parent.String()
+		}
+		if s.Error {
+			js.Error = s.StatusMessage
+		}
+		for _, ev := range s.Events {
+			js.Events = append(js.Events, jsonEvent{
+				Name:    ev.Name,
+				AfterMS: float64(ev.Time.Sub(s.Start).Microseconds()) / 1000,
+				Attrs:   attrMap(ev.Attrs),
+			})
+		}
+		if err := enc.Encode(js); err != nil {
+			return err
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+// Shutdown closes the file opened by NewFileExporter.
+func (e *WriterExporter) Shutdown(context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+func attrMap(attrs []Attr) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(attrs))
+	for _, a := range attrs {
+		m[a.Key] = a.Value
+	}
+	return m
+}
+
+// OTLPExporter posts spans to an OTLP/HTTP collector using the JSON encoding.
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+	headers map[string]string
+}
+
+// NewOTLPExporter targets endpoint, e.g. "http://localhost:4318"; the
+// "/v1/traces" path is appended unless already present.
+func NewOTLPExporter(endpoint, service string, headers map[string]string) *OTLPExporter {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPExporter{url: url, service: service, client: &http.Client{}, headers: headers}
+}
+
+// Export implements Exporter.
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(otlpRequest(e.service, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp export: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp export: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown implements Exporter.
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// The types below follow the OTLP/JSON mapping of ExportTraceServiceRequest:
+// ids are hex strings and 64-bit integers are decimal strings.
+
+// OTLPRequest is the body of POST /v1/traces.
+type OTLPRequest struct {
+	ResourceSpans []OTLPResourceSpans `json:"resourceSpans"`
+}
+
+// OTLPResourceSpans groups spans by resource (service).
+type OTLPResourceSpans struct {
+	Resource   OTLPResource     `json:"resource"`
+	ScopeSpans []OTLPScopeSpans `json:"scopeSpans"`
+}
+
+// OTLPResource describes the emitting service.
+type OTLPResource struct {
+	Attributes []OTLPKeyValue `json:"attributes"`
+}
+
+// OTLPScopeSpans groups spans by instrumentation scope.
+type OTLPScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []OTLPSpan `json:"spans"`
+}
+
+// OTLPSpan is one span on the wire.
+type OTLPSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []OTLPKeyValue `json:"attributes,omitempty"`
+	Events            []OTLPEvent    `json:"events,omitempty"`
+	Status            OTLPStatus     `json:"status"`
+}
+
+// OTLPEvent is a span event on the wire.
+type OTLPEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []OTLPKeyValue `json:"attributes,omitempty"`
+}
+
+// OTLPStatus codes: 0 unset, 1 ok, 2 error.
+type OTLPStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// OTLPKeyValue is an attribute on the wire.
+type OTLPKeyValue struct {
+	Key   string       `json:"key"`
+	Value OTLPAnyValue `json:"value"`
+}
+
+// OTLPAnyValue holds exactly one of its fields.
+type OTLPAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func otlpRequest(service string, spans []SpanData) OTLPRequest {
+	scope := OTLPScopeSpans{}
+	scope.Scope.Name = "example.com/go-class/17/tracing"
+	for _, s := range spans {
+		out := OTLPSpan{
+			TraceID:           s.SpanContext.TraceID.String(),
+			SpanID:            s.SpanContext.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttrs(s.Attrs),
+		}
+		if s.Parent.IsValid() {
+			out.ParentSpanID = s.Parent.String()
+		}
+		// Spans without an error keep the unset status, as the OTLP SDKs do;
+		// OK is reserved for an explicit override.
+		if s.Error {
+			out.Status = OTLPStatus{Code: 2, Message: s.StatusMessage}
+		}
+		for _, ev := range s.Events {
+			out.Events = append(out.Events, OTLPEvent{
+				TimeUnixNano: strconv.FormatInt(ev.Time.UnixNano(), 10),
+				Name:         ev.Name,
+				Attributes:   otlpAttrs(ev.Attrs),
+			})
+		}
+		scope.Spans = append(scope.Spans, out)
+	}
+	return OTLPRequest{ResourceSpans: []OTLPResourceSpans{{
+		Resource:   OTLPResource{Attributes: otlpAttrs([]Attr{{Key: "service.name", Value: service}})},
+		ScopeSpans: []OTLPScopeSpans{scope},
+	}}}
+}
+
+func otlpAttrs(attrs []Attr) []OTLPKeyValue {
+	out := make([]OTLPKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v OTLPAnyValue
+		switch x := a.Value.(type) {
+		case string:
+			v.StringValue = &x
+		case bool:
+			v.BoolValue = &x
+		case int:
+			s := strconv.Itoa(x)
+			v.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(x, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &x
+		default:
+			s := fmt.Sprint(x)
+			v.StringValue = &s
+		}
+		out = append(out, OTLPKeyValue{Key: a.Key, Value: v})
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+)
+
+// TraceparentHeader is the W3C Trace Context request header.
+const TraceparentHeader = "traceparent"
+
+// Extract returns ctx with the remote parent from r's traceparent header, if any.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	if sc, ok := ParseTraceparent(h.Get(TraceparentHeader)); ok {
+		return ContextWithRemote(ctx, sc)
+	}
+	return ctx
+}
+
+// Inject writes the current span of ctx into h as a traceparent header.
+func Inject(ctx context.Context, h http.Header) {
+	if sc := SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+		h.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// Transport wraps base so every outgoing request gets a client span and a
+// traceparent header pointing at it.
+func Transport(t *Tracer, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{tracer: t, base: base}
+}
+
+type transport struct {
+	tracer *Tracer
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method, SpanKindClient,
+		Attr{Key: "http.method", Value: req.Method},
+		Attr{Key: "http.url", Value: req.URL.Scheme + "://" + req.URL.Host + req.URL.Path},
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttr("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 500 {
+		span.SetError(&statusError{code: resp.StatusCode})
+	}
+	return resp, nil
+}
+
+type statusError struct{ code int }
+
+func (e *statusError) Error() string { return http.StatusText(e.code) }
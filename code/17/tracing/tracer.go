@@ -0,0 +1,190 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter ships finished spans somewhere: a file, stdout or an OTLP collector.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Config controls a Tracer.
+type Config struct {
+	Service       string
+	Exporter      Exporter
+	BatchSize     int           // spans per export, default 64
+	FlushInterval time.Duration // max time a span waits for export, default 2s
+	// MaxQueueSize caps the spans waiting for export, e.g. while the
+	// collector is down; the oldest are dropped beyond it. Default 2048.
+	MaxQueueSize int
+	// OnError is called when an export fails; nil drops the error.
+	OnError func(error)
+}
+
+// Tracer creates spans and exports them in batches from a background goroutine.
+// A nil *Tracer is valid and creates no spans.
+type Tracer struct {
+	cfg Config
+
+	mu      sync.Mutex
+	pending []SpanData
+	closed  bool
+	dropped atomic.Int64
+
+	flushMu sync.Mutex // one export at a time, in queue order
+
+	kick    chan struct{}
+	done    chan struct{}
+	stopped chan struct{} // closed when loop returns
+}
+
+// New starts a Tracer. Call Shutdown to flush the remaining spans.
+func New(cfg Config) *Tracer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 64
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 2 * time.Second
+	}
+	if cfg.MaxQueueSize <= 0 {
+		cfg.MaxQueueSize = 2048
+	}
+	cfg.MaxQueueSize = max(cfg.MaxQueueSize, cfg.BatchSize)
+	t := &Tracer{
+		cfg:     cfg,
+		kick:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// Start begins a span as a child of the span (or remote parent) in ctx and
+// returns a context carrying it.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	var parent SpanContext
+	if s := SpanFromContext(ctx); s != nil {
+		parent = s.SpanContext()
+	} else if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		parent = sc
+	}
+
+	sc := SpanContext{SpanID: newSpanID(), Sampled: true}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+	}
+	s := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:        name,
+			SpanContext: sc,
+			Parent:      parent.SpanID,
+			Kind:        kind,
+			Start:       time.Now(),
+			Attrs:       attrs,
+		},
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// Flush exports every pending span now. Concurrent calls export one after
+// the other, so each span is exported once and batches do not interleave.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return t.cfg.Exporter.Export(ctx, batch)
+}
+
+// Shutdown stops the background loop, flushes and shuts down the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.mu.Unlock()
+	close(t.done)
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := t.Flush(ctx); err != nil {
+		return err
+	}
+	return t.cfg.Exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) enqueue(s SpanData) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	if len(t.pending) >= t.cfg.MaxQueueSize {
+		t.pending = t.pending[1:]
+		t.dropped.Add(1)
+	}
+	t.pending = append(t.pending, s)
+	full := len(t.pending) >= t.cfg.BatchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dropped counts spans discarded because the export queue was full.
+func (t *Tracer) Dropped() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.dropped.Load()
+}
+
+func (t *Tracer) loop() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case <-t.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.Flush(ctx); err != nil && t.cfg.OnError != nil {
+			t.cfg.OnError(err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,268 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(tp)
+	if !ok || !sc.Sampled {
+		t.Fatalf("parse failed: %+v ok=%v", sc, ok)
+	}
+	if got := sc.Traceparent(); got != tp {
+		t.Fatalf("round trip=%q want %q", got, tp)
+	}
+	for _, bad := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestTransportPropagatesTraceparent(t *testing.T) {
+	var gotHeader string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get(TraceparentHeader)
+	}))
+	defer upstream.Close()
+
+	exp := &memoryExporter{}
+	tr := New(Config{Service: "test", Exporter: exp})
+	incoming := http.Header{}
+	incoming.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, server := tr.Start(Extract(context.Background(), incoming), "server", SpanKindServer)
+
+	client := &http.Client{Transport: Transport(tr, nil)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	server.End()
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	sc, ok := ParseTraceparent(gotHeader)
+	if !ok || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("upstream traceparent=%q", gotHeader)
+	}
+	spans := exp.all()
+	if len(spans) != 2 {
+		t.Fatalf("spans=%d want 2", len(spans))
+	}
+	clientSpan, serverSpan := spans[0], spans[1]
+	if clientSpan.Kind != SpanKindClient || clientSpan.SpanContext.SpanID != sc.SpanID {
+		t.Fatalf("upstream parent should be the client span: %+v", clientSpan)
+	}
+	if clientSpan.Parent != serverSpan.SpanContext.SpanID {
+		t.Fatalf("client span parent=%s want %s", clientSpan.Parent, serverSpan.SpanContext.SpanID)
+	}
+	if serverSpan.Parent.String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span parent=%s want remote parent", serverSpan.Parent)
+	}
+}
+
+func TestOTLPExporterToCollector(t *testing.T) {
+	received := make(chan OTLPRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var body OTLPRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- body
+	}))
+	defer collector.Close()
+
+	tr := New(Config{Service: "chatserver", Exporter: NewOTLPExporter(collector.URL, "chatserver", nil), FlushInterval: 10 * time.Millisecond})
+	ctx, parent := tr.Start(context.Background(), "chat", SpanKindInternal, Attr{Key: "llm.model", Value: "m"})
+	_, child := tr.Start(ctx, "provider.connect", SpanKindInternal)
+	child.SetError(context.DeadlineExceeded)
+	child.End()
+	parent.AddEvent("first_token", Attr{Key: "ttft_ms", Value: int64(12)})
+	parent.End()
+
+	select {
+	case body := <-received:
+		rs := body.ResourceSpans[0]
+		if v := rs.Resource.Attributes[0].Value.StringValue; v == nil || *v != "chatserver" {
+			t.Fatalf("service.name missing: %+v", rs.Resource)
+		}
+		spans := rs.ScopeSpans[0].Spans
+		if len(spans) != 2 {
+			t.Fatalf("spans=%d want 2", len(spans))
+		}
+		if spans[0].Status.Code != 2 || spans[0].ParentSpanID != spans[1].SpanID {
+			t.Fatalf("child span wrong: %+v", spans[0])
+		}
+		if spans[1].Status.Code != 0 {
+			t.Fatalf("successful span status=%+v want unset", spans[1].Status)
+		}
+		if len(spans[1].Events) != 1 || spans[1].Events[0].Name != "first_token" {
+			t.Fatalf("events=%+v", spans[1].Events)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("collector received nothing")
+	}
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tr := New(Config{Exporter: NewWriterExporter(&buf)})
+	_, span := tr.Start(context.Background(), "op", SpanKindInternal)
+	span.SetAttr("k", "v")
+	span.End()
+	span.End() // second End is ignored
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"name":"op"`) || !strings.Contains(lines[0], `"k":"v"`) {
+		t.Fatalf("output=%q", buf.String())
+	}
+}
+
+func TestNilTracerIsNoop(t *testing.T) {
+	var tr *Tracer
+	ctx, span := tr.Start(context.Background(), "op", SpanKindInternal)
+	span.SetAttr("k", "v")
+	span.End()
+	if SpanFromContext(ctx) != nil {
+		t.Fatalf("nil tracer should not store a span")
+	}
+}
+
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (m *memoryExporter) Export(_ context.Context, spans []SpanData) error {
+	m.mu.Lock()
+	m.spans = append(m.spans, spans...)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryExporter) Shutdown(context.Context) error { return nil }
+
+func (m *memoryExporter) all() []SpanData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.spans
+}
+
+// blockingExporter holds every Export until release is closed and records
+// whether two exports ever overlapped.
+type blockingExporter struct {
+	memoryExporter
+	release  chan struct{}
+	inflight atomic.Int32
+	overlap  atomic.Bool
+	started  chan struct{}
+	delay    time.Duration
+}
+
+func (b *blockingExporter) Export(ctx context.Context, spans []SpanData) error {
+	if b.inflight.Add(1) > 1 {
+		b.overlap.Store(true)
+	}
+	defer b.inflight.Add(-1)
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-b.release
+	time.Sleep(b.delay)
+	return b.memoryExporter.Export(ctx, spans)
+}
+
+func endSpans(tr *Tracer, from, to int) {
+	for i := from; i < to; i++ {
+		_, s := tr.Start(context.Background(), fmt.Sprint(i), SpanKindInternal)
+		s.End()
+	}
+}
+
+func TestQueueDropsOldestWhenFull(t *testing.T) {
+	exp := &blockingExporter{release: make(chan struct{}), started: make(chan struct{}, 1)}
+	tr := New(Config{Exporter: exp, BatchSize: 10, MaxQueueSize: 10, FlushInterval: time.Hour})
+	endSpans(tr, 0, 10) // a full batch wakes the loop, whose export hangs
+	<-exp.started
+	endSpans(tr, 10, 35)
+	if got := tr.Dropped(); got != 15 {
+		t.Fatalf("Dropped()=%d want 15", got)
+	}
+	close(exp.release)
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range exp.all() {
+		names = append(names, s.Name)
+	}
+	want := "0 1 2 3 4 5 6 7 8 9 25 26 27 28 29 30 31 32 33 34"
+	if strings.Join(names, " ") != want {
+		t.Fatalf("exported %v want %s", names, want)
+	}
+}
+
+func TestConcurrentFlushesDoNotOverlap(t *testing.T) {
+	exp := &blockingExporter{release: make(chan struct{}), started: make(chan struct{}, 1), delay: 100 * time.Microsecond}
+	close(exp.release)
+	tr := New(Config{Exporter: exp, BatchSize: 4, FlushInterval: time.Millisecond})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				endSpans(tr, g*50+i, g*50+i+1)
+				_ = tr.Flush(context.Background())
+			}
+		}()
+	}
+	wg.Wait()
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if exp.overlap.Load() {
+		t.Fatalf("exports overlapped")
+	}
+	seen := make(map[string]bool)
+	for _, s := range exp.all() {
+		if seen[s.Name] {
+			t.Fatalf("span %s exported twice", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	if len(seen) != 400 {
+		t.Fatalf("exported %d spans want 400", len(seen))
+	}
+}
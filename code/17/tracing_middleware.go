@@ -0,0 +1,95 @@
+package chatserver
+
+import (
+	"context"
+	"net/http"
+
+	"example.com/go-class/17/tracing"
+	"example.com/go-class/httpkit"
+)
+
+// TracingMiddleware starts a server span per request, continuing the trace of
+// an incoming W3C traceparent header when present.
+func TracingMiddleware(t *tracing.Tracer) httpkit.Middleware {
+	return func(next http.Handler) http.Handler {
+		if t == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := t.Start(ctx, r.Method+" "+r.URL.Path, tracing.SpanKindServer,
+				tracing.Attr{Key: "http.method", Value: r.Method},
+				tracing.Attr{Key: "http.target", Value: r.URL.Path},
+				tracing.Attr{Key: "http.user_agent", Value: r.UserAgent()},
+			)
+			defer span.End()
+			if id := httpkit.RequestID(ctx); id != "" {
+				span.SetAttr("request_id", id)
+			}
+
+			ww, rec := httpkit.NewResponseRecorder(w)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+			status := rec.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttr("http.status_code", status)
+			span.SetAttr("http.response_size", rec.BytesWritten())
+			if ttfb := rec.TimeToFirstByte(); ttfb > 0 {
+				span.AddEvent("first_byte", tracing.Attr{Key: "ttfb_ms", Value: ttfb.Milliseconds()})
+			}
+			if status >= http.StatusInternalServerError {
+				span.SetError(statusErr(status))
+			}
+		})
+	}
+}
+
+// traceMiddleware wraps mw in an internal span named "middleware.<name>". The
+// span only covers mw's own work: it ends when mw passes the request on, and
+// the rest of the chain runs under the enclosing span again, so time spent in
+// auth is not mixed with handler time.
+func traceMiddleware(t *tracing.Tracer, name string, mw httpkit.Middleware) httpkit.Middleware {
+	if t == nil {
+		return mw
+	}
+	return func(next http.Handler) http.Handler {
+		inner := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if m, ok := ctx.Value(middlewareSpanKey{}).(middlewareSpan); ok {
+				m.span.End()
+				ctx = tracing.ContextWithSpan(ctx, m.parent)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent := tracing.SpanFromContext(r.Context())
+			ctx, span := t.Start(r.Context(), "middleware."+name, tracing.SpanKindInternal)
+			defer span.End() // mw answered the request itself
+			ctx = context.WithValue(ctx, middlewareSpanKey{}, middlewareSpan{span: span, parent: parent})
+			inner.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type middlewareSpanKey struct{}
+
+type middlewareSpan struct {
+	span, parent *tracing.Span
+}
+
+// traceHandler wraps h in an internal span with the given name.
+func traceHandler(t *tracing.Tracer, name string, h http.Handler) http.Handler {
+	if t == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := t.Start(r.Context(), name, tracing.SpanKindInternal)
+		defer span.End()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type statusErr int
+
+func (e statusErr) Error() string { return http.StatusText(int(e)) }
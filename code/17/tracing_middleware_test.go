@@ -0,0 +1,88 @@
+package chatserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/go-class/17/tracing"
+)
+
+// tracedChat serves one request through NewMux and returns the spans by name.
+func tracedChat(t *testing.T, cfg Config, header map[string]string) (*httptest.ResponseRecorder, map[string]tracing.SpanData) {
+	t.Helper()
+	rec := &spanRecorder{}
+	cfg.Tracer = tracing.New(tracing.Config{Exporter: rec})
+	resp := chat(t, cfg, "hi", header)
+	if err := cfg.Tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := make(map[string]tracing.SpanData)
+	for _, s := range rec.spans {
+		spans[s.Name] = s
+	}
+	return resp, spans
+}
+
+func hasEvent(s tracing.SpanData, name string) bool {
+	for _, ev := range s.Events {
+		if ev.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestChatSpans(t *testing.T) {
+	up := newUpstream(t, streamChunks("a", "b"))
+	cfg := testConfig()
+	cfg.APIKey, cfg.BaseURL = "test-key", up.URL
+	resp, spans := tracedChat(t, cfg, map[string]string{tracing.TraceparentHeader: incomingTraceparent})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status=%d", resp.Code)
+	}
+
+	server := spans["POST /chat"]
+	for _, name := range []string{"middleware.cors", "middleware.security_headers", "middleware.auth", "handler.chat"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("no %s span; got %v", name, spanNames(spans))
+		}
+		if s.Parent != server.SpanContext.SpanID {
+			t.Fatalf("%s parent=%s want the server span", name, s.Parent)
+		}
+	}
+	auth, handler := spans["middleware.auth"], spans["handler.chat"]
+	if auth.End.After(handler.Start) {
+		t.Fatalf("auth span (%v-%v) overlaps the handler (from %v)", auth.Start, auth.End, handler.Start)
+	}
+	for _, name := range []string{"provider.connect", "provider.stream"} {
+		if s, ok := spans[name]; !ok || s.Parent != handler.SpanContext.SpanID {
+			t.Fatalf("%s missing or not under handler.chat: %+v", name, s)
+		}
+	}
+	stream := spans["provider.stream"]
+	if !hasEvent(stream, "first_token") || !hasEvent(stream, "stream_end") {
+		t.Fatalf("provider.stream events=%+v want first_token and stream_end", stream.Events)
+	}
+}
+
+func TestAuthSpanOnRejection(t *testing.T) {
+	_, spans := tracedChat(t, testConfig(), map[string]string{"Authorization": "Bearer bad"})
+	auth, ok := spans["middleware.auth"]
+	if !ok || auth.End.IsZero() {
+		t.Fatalf("rejected request has no finished auth span: %v", spanNames(spans))
+	}
+	if _, ok := spans["handler.chat"]; ok {
+		t.Fatalf("handler ran after auth rejected the request")
+	}
+}
+
+func spanNames(spans map[string]tracing.SpanData) []string {
+	var names []string
+	for n := range spans {
+		names = append(names, n)
+	}
+	return names
+}
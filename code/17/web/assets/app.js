@@ -45,8 +45,7 @@ loginForm.addEventListener("submit", async (e) => {
       }),
     });
     if (!resp.ok) {
-      const msg = await resp.text();
-      throw new Error(`登录失败 (${resp.status}): ${msg || "unknown error"}`);
+      throw new Error(`登录失败 (${resp.status}): ${await problemText(resp)}`);
     }
     const data = await resp.json();
     token = data.token;
@@ -173,8 +172,7 @@ async function streamChat(message) {
   });
 
   if (!resp.ok || !resp.body) {
-    const msg = await resp.text();
-    throw new Error(`请求失败 (${resp.status}): ${msg || "unknown error"}`);
+    throw new Error(`请求失败 (${resp.status}): ${await problemText(resp)}`);
   }
 
   const reader = resp.body.getReader();
@@ -182,6 +180,7 @@ async function streamChat(message) {
   const target = appendMessage("model", "");
   let buffer = "";
   let currentEvent = "message";
+  let requestId = resp.headers.get("X-Request-ID") || "";
 
   while (true) {
     const { value, done } = await reader.read();
@@ -197,8 +196,12 @@ async function streamChat(message) {
         }
         if (line.startsWith("data: ")) {
           const payload = line.slice(6);
+          if (currentEvent === "meta") {
+            requestId = parseMeta(payload).request_id || requestId;
+            continue;
+          }
           if (currentEvent === "error") {
-            throw new Error(payload);
+            throw new Error(describeProblem(parseMeta(payload), requestId) || payload);
           }
           target.textContent += payload;
           chatLog.scrollTop = chatLog.scrollHeight;
@@ -208,3 +211,30 @@ async function streamChat(message) {
     }
   }
 }
+
+// problemText renders an RFC 9457 problem+json body, falling back to plain text.
+async function problemText(resp) {
+  const body = await resp.text();
+  if (!(resp.headers.get("Content-Type") || "").includes("json")) {
+    return body || "unknown error";
+  }
+  return describeProblem(parseMeta(body), resp.headers.get("X-Request-ID")) || body || "unknown error";
+}
+
+function describeProblem(problem, requestId) {
+  if (!problem.title) return "";
+  let text = problem.detail || problem.title;
+  if (Array.isArray(problem.errors) && problem.errors.length > 0) {
+    text += ": " + problem.errors.map((e) => `${e.field} ${e.reason}`).join("; ");
+  }
+  const id = problem.request_id || requestId;
+  return id ? `${text} (request id: ${id})` : text;
+}
+
+function parseMeta(payload) {
+  try {
+    return JSON.parse(payload);
+  } catch {
+    return {};
+  }
+}
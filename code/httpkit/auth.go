@@ -0,0 +1,89 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AuthOptions configures BearerAuthMiddleware.
+type AuthOptions struct {
+	// Secret is the HS256 key; an empty secret disables the check.
+	Secret string
+	// Public reports requests that skip auth, usually Router.IsPublic so the
+	// route table is the single source of truth.
+	Public func(r *http.Request) bool
+}
+
+type subjectKey struct{}
+
+// Subject returns the JWT subject stored by BearerAuthMiddleware.
+func Subject(ctx context.Context) (string, bool) {
+	sub, ok := ctx.Value(subjectKey{}).(string)
+	return sub, ok
+}
+
+// BearerAuthMiddleware validates JWT Bearer tokens unless opts.Public accepts the request.
+// The subject is stored in the context and added to the access log as "user".
+func BearerAuthMiddleware(opts AuthOptions) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if opts.Secret == "" || (opts.Public != nil && opts.Public(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			auth := r.Header.Get("Authorization")
+			if !strings.HasPrefix(auth, "Bearer ") {
+				unauthorized(w, r, "missing bearer token")
+				return
+			}
+			raw := strings.TrimPrefix(auth, "Bearer ")
+			claims, err := ParseJWT(opts.Secret, raw)
+			if err != nil || claims == nil || claims.Subject == "" {
+				unauthorized(w, r, "invalid or expired token")
+				return
+			}
+			AddLogAttrs(r.Context(), slog.String("user", claims.Subject))
+			ctx := context.WithValue(r.Context(), subjectKey{}, claims.Subject)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	WriteProblem(w, r, &Problem{Status: http.StatusUnauthorized, Detail: detail})
+}
+
+// ParseJWT verifies an HS256 token and returns its registered claims.
+func ParseJWT(secret, token string) (*jwt.RegisteredClaims, error) {
+	parsed, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(t *jwt.Token) (any, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := parsed.Claims.(*jwt.RegisteredClaims); ok && parsed.Valid {
+		return claims, nil
+	}
+	return nil, errors.New("invalid claims")
+}
+
+// IssueJWT signs an HS256 token for sub that expires after ttl.
+func IssueJWT(secret, sub string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   sub,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
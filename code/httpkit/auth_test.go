@@ -0,0 +1,61 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBearerAuthMiddleware(t *testing.T) {
+	var sub string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, _ = Subject(r.Context())
+	})
+	rt := NewRouter(
+		Route{Method: http.MethodPost, Path: "/login", Handler: handler, Public: true},
+		Route{Method: http.MethodGet, Path: "/assets/", Handler: handler, Public: true},
+		Route{Method: http.MethodPost, Path: "/chat", Handler: handler},
+	)
+	h := BearerAuthMiddleware(AuthOptions{Secret: "secret", Public: rt.IsPublic})(rt)
+	valid, err := IssueJWT("secret", "alice", time.Minute)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	expired, _ := IssueJWT("secret", "alice", -time.Minute)
+	forged, _ := IssueJWT("other", "alice", time.Minute)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"public exact", http.MethodPost, "/login", "", http.StatusOK},
+		{"public subtree", http.MethodGet, "/assets/app.js", "", http.StatusOK},
+		{"preflight", http.MethodOptions, "/chat", "", http.StatusNoContent},
+		{"wrong method skips auth", http.MethodGet, "/chat", "", http.StatusMethodNotAllowed},
+		{"missing token", http.MethodPost, "/chat", "", http.StatusUnauthorized},
+		{"expired token", http.MethodPost, "/chat", expired, http.StatusUnauthorized},
+		{"wrong secret", http.MethodPost, "/chat", forged, http.StatusUnauthorized},
+		{"valid token", http.MethodPost, "/chat", valid, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sub = ""
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status=%d want %d", rec.Code, tc.want)
+			}
+			if tc.token == valid && sub != "alice" {
+				t.Fatalf("subject=%q want alice", sub)
+			}
+		})
+	}
+}
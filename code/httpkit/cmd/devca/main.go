@@ -0,0 +1,65 @@
+// Command devca writes a self-signed development CA, a server certificate and
+// optionally a client certificate for trying HTTPS and mTLS locally.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"example.com/go-class/httpkit"
+)
+
+func main() {
+	out := flag.String("out", "certs", "output directory")
+	hosts := flag.String("hosts", "localhost,127.0.0.1,::1", "comma-separated server DNS names and IPs")
+	client := flag.String("client", "", "also issue a client certificate with this common name")
+	ttl := flag.Duration("ttl", 30*24*time.Hour, "certificate lifetime")
+	flag.Parse()
+
+	if err := run(*out, strings.Split(*hosts, ","), *client, *ttl); err != nil {
+		slog.Error("devca failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, hosts []string, client string, ttl time.Duration) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	ca, err := httpkit.NewDevCA("go-class dev CA", ttl)
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{"ca.pem": ca.CertPEM, "ca-key.pem": ca.KeyPEM}
+
+	certPEM, keyPEM, err := ca.IssueServer(hosts, ttl)
+	if err != nil {
+		return err
+	}
+	files["server.pem"], files["server-key.pem"] = certPEM, keyPEM
+
+	if client != "" {
+		certPEM, keyPEM, err := ca.IssueClient(client, ttl)
+		if err != nil {
+			return err
+		}
+		files["client.pem"], files["client-key.pem"] = certPEM, keyPEM
+	}
+
+	for name, data := range files {
+		mode := os.FileMode(0o644)
+		if strings.HasSuffix(name, "-key.pem") {
+			mode = 0o600
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, mode); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("wrote %d files to %s\n", len(files), dir)
+	return nil
+}
@@ -0,0 +1,86 @@
+// Command precompress writes name.br and name.gz next to every compressible
+// file under the given directories, for httpkit.StaticFiles. Run it from
+// go:generate before embedding static assets. A variant is only kept when
+// it is smaller than the original; stale ones are removed.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+func main() {
+	exts := flag.String("ext", ".html,.css,.js,.mjs,.json,.map,.svg,.txt,.xml", "comma-separated extensions to compress")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: precompress [-ext list] dir...")
+		os.Exit(2)
+	}
+	for _, dir := range flag.Args() {
+		if err := run(dir, strings.Split(*exts, ",")); err != nil {
+			slog.Error("precompress failed", "dir", dir, "err", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func run(dir string, exts []string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !hasExt(path, exts) {
+			return err
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, v := range []struct {
+			ext string
+			new func(io.Writer) io.WriteCloser
+		}{
+			{".br", func(w io.Writer) io.WriteCloser { return brotli.NewWriterLevel(w, brotli.BestCompression) }},
+			{".gz", func(w io.Writer) io.WriteCloser {
+				zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+				return zw
+			}},
+		} {
+			var buf bytes.Buffer
+			zw := v.new(&buf)
+			if _, err := zw.Write(src); err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			if buf.Len() >= len(src) {
+				if err := os.Remove(path + v.ext); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				continue
+			}
+			if err := os.WriteFile(path+v.ext, buf.Bytes(), 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("%s%s: %d -> %d bytes\n", path, v.ext, len(src), buf.Len())
+		}
+		return nil
+	})
+}
+
+func hasExt(path string, exts []string) bool {
+	for _, e := range exts {
+		if strings.EqualFold(filepath.Ext(path), strings.TrimSpace(e)) {
+			return true
+		}
+	}
+	return false
+}
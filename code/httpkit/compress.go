@@ -0,0 +1,373 @@
+package httpkit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DefaultCompressMinSize is the default CompressOptions.MinSize: below about
+// one packet compression saves nothing worth the CPU.
+const DefaultCompressMinSize = 1024
+
+// DefaultCompressibleTypes is the default CompressOptions.ContentTypes.
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// CompressOptions configures CompressMiddleware.
+type CompressOptions struct {
+	// MinSize is the smallest body worth compressing; zero means
+	// DefaultCompressMinSize. Responses that flush earlier, such as SSE, are
+	// compressed regardless.
+	MinSize int
+	// ContentTypes lists compressible media types, each with at most one "*"
+	// wildcard ("text/*", "application/*+json"). Nil means DefaultCompressibleTypes.
+	ContentTypes []string
+}
+
+// brotliLevel is the brotli quality for dynamic responses. Quality 5 compresses
+// about as fast as gzip's default while still beating it on size; higher levels
+// are for build-time precompression.
+const brotliLevel = 5
+
+// compressEncodings lists supported codings, preferred first on equal q-values.
+var compressEncodings = []string{"br", "gzip"}
+
+// CompressMiddleware compresses responses with brotli or gzip as negotiated by
+// Accept-Encoding. The decision waits for MinSize bytes or a Flush, so small
+// bodies go out untouched; every Flush pushes compressed bytes to the client,
+// keeping SSE streams live. Responses that already carry a Content-Encoding,
+// partial content and Cache-Control: no-transform pass through.
+func CompressMiddleware(opts CompressOptions) Middleware {
+	if opts.MinSize <= 0 {
+		opts.MinSize = DefaultCompressMinSize
+	}
+	if opts.ContentTypes == nil {
+		opts.ContentTypes = DefaultCompressibleTypes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept-Encoding")
+			enc := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if enc == "" || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, opts: &opts, encoding: enc}
+			next.ServeHTTP(cw.wrap(), r)
+			cw.close()
+		})
+	}
+}
+
+// acceptedEncodings returns the supported codings header accepts, best first.
+func acceptedEncodings(header string) []string {
+	q := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "x-gzip" {
+			name = "gzip"
+		}
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		if name != "" {
+			q[name] = weight
+		}
+	}
+	var accepted []string
+	for _, enc := range compressEncodings {
+		weight, ok := q[enc]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > 0 {
+			accepted = append(accepted, enc)
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return qOf(q, accepted[i]) > qOf(q, accepted[j]) })
+	return accepted
+}
+
+func qOf(q map[string]float64, enc string) float64 {
+	if w, ok := q[enc]; ok {
+		return w
+	}
+	return q["*"]
+}
+
+// negotiateEncoding returns the best supported coding, or "" for identity.
+func negotiateEncoding(header string) string {
+	if accepted := acceptedEncodings(header); len(accepted) > 0 {
+		return accepted[0]
+	}
+	return ""
+}
+
+// encoder is the part of gzip.Writer and brotli.Writer compressWriter uses.
+type encoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+var (
+	gzipPool   = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	brotliPool = sync.Pool{New: func() any { return brotli.NewWriterLevel(io.Discard, brotliLevel) }}
+)
+
+func getEncoder(name string, w io.Writer) encoder {
+	if name == "br" {
+		bw := brotliPool.Get().(*brotli.Writer)
+		bw.Reset(w)
+		return bw
+	}
+	gw := gzipPool.Get().(*gzip.Writer)
+	gw.Reset(w)
+	return gw
+}
+
+func putEncoder(e encoder) {
+	switch e := e.(type) {
+	case *brotli.Writer:
+		brotliPool.Put(e)
+	case *gzip.Writer:
+		gzipPool.Put(e)
+	}
+}
+
+// compressWriter buffers the start of a response until it knows whether to
+// compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	opts     *CompressOptions
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	enc      encoder
+	hijacked bool
+}
+
+// wrap returns cw with http.Hijacker and io.ReaderFrom added exactly when the
+// underlying writer has them, as NewResponseRecorder does.
+func (cw *compressWriter) wrap() http.ResponseWriter {
+	_, h := cw.ResponseWriter.(http.Hijacker)
+	_, rf := cw.ResponseWriter.(io.ReaderFrom)
+	switch {
+	case h && rf:
+		return struct {
+			*compressWriter
+			compressHijacker
+			compressReaderFrom
+		}{cw, compressHijacker{cw}, compressReaderFrom{cw}}
+	case h:
+		return struct {
+			*compressWriter
+			compressHijacker
+		}{cw, compressHijacker{cw}}
+	case rf:
+		return struct {
+			*compressWriter
+			compressReaderFrom
+		}{cw, compressReaderFrom{cw}}
+	}
+	return cw
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(code) // let net/http report the superfluous call
+		return
+	}
+	if cw.status != 0 {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+	if !bodyAllowed(code) {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.opts.MinSize {
+			return len(p), nil
+		}
+		cw.decide(true)
+		if err := cw.writeBuffered(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush commits to a decision, then pushes everything through the encoder
+// and the connection.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(true)
+		_ = cw.writeBuffered()
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// decide writes the header, switching to compression when big is true and
+// the response qualifies.
+func (cw *compressWriter) decide(big bool) {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if big && cw.compressible() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		// The compressed body is a different representation.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.enc = getEncoder(cw.encoding, cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if !bodyAllowed(cw.status) || cw.status == http.StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" ||
+		strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && matchMediaType(mediaType, cw.opts.ContentTypes)
+}
+
+func (cw *compressWriter) writeBuffered() error {
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close finishes the response after the handler returns.
+func (cw *compressWriter) close() {
+	if cw.hijacked {
+		if cw.enc != nil {
+			putEncoder(cw.enc)
+			cw.enc = nil
+		}
+		return
+	}
+	if !cw.decided {
+		cw.decide(false)
+		_ = cw.writeBuffered()
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		putEncoder(cw.enc)
+		cw.enc = nil
+	}
+}
+
+type compressHijacker struct{ cw *compressWriter }
+
+// Hijack hands over the connection; bytes still held back for the
+// compression decision are dropped, as no HTTP response follows.
+func (h compressHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.cw.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		h.cw.hijacked = true
+		h.cw.buf = nil
+	}
+	return conn, rw, err
+}
+
+type compressReaderFrom struct{ cw *compressWriter }
+
+// ReadFrom keeps the underlying fast path, such as sendfile, once the
+// response is known to go out uncompressed; otherwise it copies through the
+// encoder.
+func (rf compressReaderFrom) ReadFrom(src io.Reader) (int64, error) {
+	if rf.cw.decided && rf.cw.enc == nil {
+		return rf.cw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	}
+	return io.Copy(rf.cw, src)
+}
+
+// addVary adds field to the Vary header unless an earlier layer already did.
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+func bodyAllowed(code int) bool {
+	return code != http.StatusNoContent && code != http.StatusNotModified && code >= 200
+}
+
+func matchMediaType(mediaType string, patterns []string) bool {
+	for _, p := range patterns {
+		prefix, suffix, wild := strings.Cut(p, "*")
+		if !wild && mediaType == p {
+			return true
+		}
+		if wild && len(mediaType) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package httpkit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestAcceptedEncodings(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.1, gzip;q=0.8", "gzip"},
+		{"x-gzip", "gzip"},
+		{"BR", "br"},
+	}
+	for _, tc := range cases {
+		if got := negotiateEncoding(tc.header); got != tc.want {
+			t.Errorf("%q: got %q want %q", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	big := strings.Repeat(`{"message":"hello"},`, 200)
+	cases := []struct {
+		name    string
+		accept  string
+		ctype   string
+		body    string
+		header  map[string]string
+		status  int
+		wantEnc string
+	}{
+		{name: "gzip json", accept: "gzip", ctype: "application/json", body: big, wantEnc: "gzip"},
+		{name: "brotli preferred", accept: "gzip, br", ctype: "application/json", body: big, wantEnc: "br"},
+		{name: "problem json", accept: "gzip", ctype: ProblemContentType, body: big, status: 400, wantEnc: "gzip"},
+		{name: "no accept-encoding", ctype: "application/json", body: big},
+		{name: "below min size", accept: "gzip", ctype: "application/json", body: `{"ok":true}`},
+		{name: "not in allowlist", accept: "gzip", ctype: "image/png", body: big},
+		{name: "sniffed text", accept: "gzip", body: big, wantEnc: "gzip"},
+		{name: "already encoded", accept: "gzip", ctype: "text/plain", body: big, header: map[string]string{"Content-Encoding": "br"}, wantEnc: "br"},
+		{name: "no-transform", accept: "gzip", ctype: "text/plain", body: big, header: map[string]string{"Cache-Control": "no-transform"}},
+		{name: "not modified", accept: "gzip", ctype: "text/plain", status: http.StatusNotModified},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := CompressMiddleware(CompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.ctype != "" {
+					w.Header().Set("Content-Type", tc.ctype)
+				}
+				for k, v := range tc.header {
+					w.Header().Set(k, v)
+				}
+				w.Header().Set("ETag", `"v1"`)
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				// Write in pieces so the size decision spans calls.
+				for i := 0; i < len(tc.body); i += 500 {
+					_, _ = io.WriteString(w, tc.body[i:min(i+500, len(tc.body))])
+				}
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept-Encoding", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tc.wantEnc {
+				t.Fatalf("Content-Encoding=%q want %q", got, tc.wantEnc)
+			}
+			if vary := rec.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+				t.Fatalf("Vary=%q", rec.Header().Get("Vary"))
+			}
+			if tc.status != 0 && rec.Code != tc.status {
+				t.Fatalf("status=%d want %d", rec.Code, tc.status)
+			}
+			switch tc.wantEnc {
+			case "gzip":
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _ := io.ReadAll(zr)
+				if string(got) != tc.body {
+					t.Fatalf("gunzipped body differs")
+				}
+				if rec.Header().Get("ETag") != `W/"v1"` {
+					t.Fatalf("ETag=%q want weak", rec.Header().Get("ETag"))
+				}
+			case "br":
+				if tc.header != nil {
+					break // the handler's own encoding, passed through
+				}
+				got, err := io.ReadAll(brotli.NewReader(rec.Body))
+				if err != nil || string(got) != tc.body {
+					t.Fatalf("brotli body does not decode to the original: %v", err)
+				}
+				if rec.Body.Len() >= len(tc.body) {
+					t.Fatalf("brotli body not smaller: %d", rec.Body.Len())
+				}
+			default:
+				if rec.Body.String() != tc.body {
+					t.Fatalf("body changed without Content-Encoding")
+				}
+			}
+		})
+	}
+}
+
+func TestCompressMiddlewareStreamsSSE(t *testing.T) {
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	}
+	for enc, decode := range decoders {
+		t.Run(enc, func(t *testing.T) {
+			events := make(chan string)
+			srv := httptest.NewServer(CompressMiddleware(CompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.(http.Flusher).Flush() // send headers before the first event
+				for ev := range events {
+					_, _ = io.WriteString(w, "data: "+ev+"\n\n")
+					w.(http.Flusher).Flush()
+				}
+			})))
+			defer srv.Close()
+			defer close(events)
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header.Set("Accept-Encoding", enc)
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.Header.Get("Content-Encoding") != enc {
+				t.Fatalf("Content-Encoding=%q", resp.Header.Get("Content-Encoding"))
+			}
+
+			lines := make(chan string)
+			go func() {
+				defer close(lines)
+				zr, err := decode(resp.Body)
+				if err != nil {
+					return
+				}
+				sc := bufio.NewScanner(zr)
+				for sc.Scan() {
+					if sc.Text() != "" {
+						lines <- sc.Text()
+					}
+				}
+			}()
+			// Each event must reach the client while the handler is still running.
+			for _, ev := range []string{"one", "two"} {
+				events <- ev
+				select {
+				case got := <-lines:
+					if got != "data: "+ev {
+						t.Fatalf("got %q want data: %s", got, ev)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatalf("event %q stuck in the compression buffer", ev)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressMiddlewareKeepsOptionalInterfaces(t *testing.T) {
+	big := strings.Repeat("hello, compressed world\n", 200)
+	var sawReaderFrom, sawHijacker bool
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, sawReaderFrom = w.(io.ReaderFrom)
+		_, sawHijacker = w.(http.Hijacker)
+		if r.URL.Path == "/hijack" {
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %v", err)
+				return
+			}
+			defer conn.Close()
+			_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			_ = rw.Flush()
+			return
+		}
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		_, _ = w.(io.ReaderFrom).ReadFrom(strings.NewReader(big))
+	}), LoggingMiddleware(NewLogger(LogOptions{Output: io.Discard})), CompressMiddleware(CompressOptions{}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	get := func(path string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body io.Reader = resp.Body
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			if body, err = gzip.NewReader(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(b)
+	}
+
+	for _, tc := range []struct{ ctype, wantEnc string }{
+		{"text/plain", "gzip"},
+		{"application/octet-stream", ""},
+	} {
+		resp, body := get("/copy?type=" + tc.ctype)
+		if resp.Header.Get("Content-Encoding") != tc.wantEnc || body != big {
+			t.Fatalf("%s via ReadFrom: Content-Encoding=%q, %d bytes", tc.ctype, resp.Header.Get("Content-Encoding"), len(body))
+		}
+	}
+	if !sawReaderFrom || !sawHijacker {
+		t.Fatalf("compressing writer hides io.ReaderFrom=%v http.Hijacker=%v", sawReaderFrom, sawHijacker)
+	}
+	if resp, body := get("/hijack"); body != "hijacked" || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("hijacked response: %q %v", body, resp.Header)
+	}
+
+	// httptest.ResponseRecorder has neither, so neither may be advertised.
+	CompressMiddleware(CompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, sawReaderFrom = w.(io.ReaderFrom)
+		_, sawHijacker = w.(http.Hijacker)
+	})).ServeHTTP(httptest.NewRecorder(), func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		return req
+	}())
+	if sawReaderFrom || sawHijacker {
+		t.Fatalf("advertised io.ReaderFrom=%v http.Hijacker=%v the writer lacks", sawReaderFrom, sawHijacker)
+	}
+}
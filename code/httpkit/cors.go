@@ -0,0 +1,169 @@
+package httpkit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures CORSMiddleware.
+type CORSOptions struct {
+	// AllowedOrigins lists exact origins ("https://app.example.com"), wildcard
+	// subdomains ("https://*.example.com") or "*" for any origin. Empty
+	// disables CORS.
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, HEAD and POST.
+	AllowedMethods []string
+	// AllowedHeaders lists request headers a preflight may ask for; "*"
+	// allows any. Defaults to Authorization, Content-Type and X-Request-ID.
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts may read, e.g. X-Request-ID.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and Authorization. The
+	// matching origin is echoed instead of "*", as the spec requires.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight; 0 omits the header.
+	MaxAge time.Duration
+}
+
+// CORSMiddleware implements CORS for simple and preflighted requests. Preflights
+// are answered here with 204, so it must sit before auth in the chain.
+// Requests from origins that are not allowed get no CORS headers and the
+// browser blocks them.
+func CORSMiddleware(opts CORSOptions) Middleware {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	headers := opts.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Authorization", "Content-Type", RequestIDHeader}
+	}
+	anyOrigin, anyHeader := false, false
+	for _, o := range opts.AllowedOrigins {
+		anyOrigin = anyOrigin || o == "*"
+	}
+	allowedHeaders := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		anyHeader = anyHeader || h == "*"
+		allowedHeaders[strings.ToLower(h)] = true
+	}
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := ""
+	if opts.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(opts.AllowedOrigins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			// The answer depends on Origin unless every origin gets a literal "*".
+			if !anyOrigin || opts.AllowCredentials {
+				h.Add("Vary", "Origin")
+			}
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+			if origin == "" || !(anyOrigin || originAllowed(origin, opts.AllowedOrigins)) {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowOrigin := "*"
+			if !anyOrigin || opts.AllowCredentials {
+				allowOrigin = origin
+			}
+
+			if !preflight {
+				h.Set("Access-Control-Allow-Origin", allowOrigin)
+				if opts.AllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+				if exposed != "" {
+					h.Set("Access-Control-Expose-Headers", exposed)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+			if !containsFold(methods, method) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			requested := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+			if !anyHeader {
+				for _, name := range requested {
+					if !allowedHeaders[name] {
+						w.WriteHeader(http.StatusNoContent)
+						return
+					}
+				}
+			}
+			h.Set("Access-Control-Allow-Origin", allowOrigin)
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(requested) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+			}
+			if opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if maxAge != "" {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// originAllowed matches origin against exact entries and "scheme://*.domain"
+// wildcards; a wildcard matches subdomains at any depth but not the apex.
+func originAllowed(origin string, allowed []string) bool {
+	origin = strings.ToLower(origin)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == origin {
+			return true
+		}
+		scheme, host, ok := strings.Cut(a, "://*.")
+		if !ok {
+			continue
+		}
+		prefix, suffix := scheme+"://", "."+host
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			len(origin) > len(prefix)+len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseHeaderList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	var reached bool
+	h := CORSMiddleware(CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		ExposedHeaders:   []string{RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	cases := []struct {
+		name        string
+		method      string
+		origin      string
+		reqMethod   string
+		reqHeaders  string
+		wantStatus  int
+		wantOrigin  string
+		wantReached bool
+	}{
+		{"simple allowed", http.MethodGet, "https://app.example.com", "", "", http.StatusOK, "https://app.example.com", true},
+		{"simple wildcard subdomain", http.MethodGet, "https://a.b.example.org", "", "", http.StatusOK, "https://a.b.example.org", true},
+		{"wildcard excludes apex", http.MethodGet, "https://example.org", "", "", http.StatusOK, "", true},
+		{"wildcard checks scheme", http.MethodGet, "http://a.example.org", "", "", http.StatusOK, "", true},
+		{"simple foreign", http.MethodGet, "https://evil.example", "", "", http.StatusOK, "", true},
+		{"no origin", http.MethodGet, "", "", "", http.StatusOK, "", true},
+		{"preflight allowed", http.MethodOptions, "https://app.example.com", "POST", "Authorization, Content-Type", http.StatusNoContent, "https://app.example.com", false},
+		{"preflight bad method", http.MethodOptions, "https://app.example.com", "DELETE", "", http.StatusNoContent, "", false},
+		{"preflight bad header", http.MethodOptions, "https://app.example.com", "POST", "X-Secret", http.StatusNoContent, "", false},
+		{"preflight foreign", http.MethodOptions, "https://evil.example", "POST", "", http.StatusNoContent, "", false},
+		{"plain options", http.MethodOptions, "https://app.example.com", "", "", http.StatusOK, "https://app.example.com", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest(tc.method, "/chat", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.reqMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tc.reqMethod)
+			}
+			if tc.reqHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", tc.reqHeaders)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status=%d want %d", rec.Code, tc.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+				t.Fatalf("allow-origin=%q want %q", got, tc.wantOrigin)
+			}
+			if reached != tc.wantReached {
+				t.Fatalf("reached next=%v want %v", reached, tc.wantReached)
+			}
+			if vary := rec.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Origin" {
+				t.Fatalf("Vary=%v want Origin first", vary)
+			}
+		})
+	}
+}
+
+func TestCORSPreflightHeaders(t *testing.T) {
+	h := CORSMiddleware(CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})(http.NotFoundHandler())
+	req := httptest.NewRequest(http.MethodOptions, "/chat", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "authorization,content-type")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	want := map[string]string{
+		"Access-Control-Allow-Methods":     "GET, HEAD, POST",
+		"Access-Control-Allow-Headers":     "authorization, content-type",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Fatalf("%s=%q want %q", k, got, v)
+		}
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 3 {
+		t.Fatalf("Vary=%v want Origin and both request headers", vary)
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	h := CORSMiddleware(CORSOptions{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{RequestIDHeader}})(http.NotFoundHandler())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("allow-origin=%q want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != RequestIDHeader {
+		t.Fatalf("expose=%q want %q", got, RequestIDHeader)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "" {
+		t.Fatalf("Vary=%q: a literal * does not depend on Origin", vary)
+	}
+}
@@ -0,0 +1,102 @@
+package httpkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes caps bodies read by DecodeJSON when the route sets no
+// Route.MaxBodyBytes.
+const DefaultMaxBodyBytes = 1 << 20
+
+// DecodeError is returned by DecodeJSON. Msg is safe to show to clients and
+// Status is 400, 413 or 415.
+type DecodeError struct {
+	Status int
+	Msg    string
+	Err    error
+}
+
+func (e *DecodeError) Error() string { return e.Msg }
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// DecodeStatus returns the status for a DecodeJSON error, 400 by default.
+func DecodeStatus(err error) int {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		return de.Status
+	}
+	return http.StatusBadRequest
+}
+
+// DecodeJSON strictly decodes a request body into dst: the Content-Type must be
+// JSON, unknown fields and trailing data are rejected, and the body is capped at
+// the route's Route.MaxBodyBytes, or DefaultMaxBodyBytes when it sets none.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return &DecodeError{Status: http.StatusUnsupportedMediaType, Msg: "Content-Type must be application/json", Err: err}
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes(r.Context())))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	// A second value, even whitespace-separated, means the body was not one document.
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err)
+		}
+		return &DecodeError{Status: http.StatusBadRequest, Msg: "request body must contain a single JSON value", Err: err}
+	}
+	return nil
+}
+
+type bodyLimitKey struct{}
+
+func withMaxBodyBytes(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, bodyLimitKey{}, limit)
+}
+
+func maxBodyBytes(ctx context.Context) int64 {
+	if limit, ok := ctx.Value(bodyLimitKey{}).(int64); ok {
+		return limit
+	}
+	return DefaultMaxBodyBytes
+}
+
+func decodeError(err error) *DecodeError {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		tooLarge  *http.MaxBytesError
+	)
+	msg := "malformed JSON"
+	switch {
+	case errors.As(err, &tooLarge):
+		return &DecodeError{Status: http.StatusRequestEntityTooLarge, Msg: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), Err: err}
+	case errors.Is(err, io.EOF):
+		msg = "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		msg = "malformed JSON: unexpected end of body"
+	case errors.As(err, &syntaxErr):
+		msg = fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		msg = fmt.Sprintf("field %q must be %s", typeErr.Field, typeErr.Type)
+	case errors.As(err, &typeErr):
+		msg = fmt.Sprintf("body must be a JSON %s", typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for DisallowUnknownFields.
+		msg = "unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	}
+	return &DecodeError{Status: http.StatusBadRequest, Msg: msg, Err: err}
+}
@@ -0,0 +1,128 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type payload struct {
+		Message string `json:"message"`
+		Count   int    `json:"count"`
+	}
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+		wantMsg     string
+	}{
+		{"ok", "application/json", `{"message":"hi","count":2}`, http.StatusOK, ""},
+		{"ok with charset", "application/json; charset=utf-8", `{"message":"hi"}`, http.StatusOK, ""},
+		{"ok vendor type", "application/merge-patch+json", `{"message":"hi"}`, http.StatusOK, ""},
+		{"trailing whitespace", "application/json", "{\"message\":\"hi\"}\n", http.StatusOK, ""},
+		{"missing content type", "", `{"message":"hi"}`, http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"form content type", "application/x-www-form-urlencoded", `message=hi`, http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"empty", "application/json", ``, http.StatusBadRequest, "request body is empty"},
+		{"syntax", "application/json", `{"message":}`, http.StatusBadRequest, "malformed JSON at offset 12"},
+		{"truncated", "application/json", `{"message":"hi"`, http.StatusBadRequest, "malformed JSON: unexpected end of body"},
+		{"wrong type", "application/json", `{"count":"two"}`, http.StatusBadRequest, `field "count" must be int`},
+		{"unknown field", "application/json", `{"message":"hi","admin":true}`, http.StatusBadRequest, `unknown field "admin"`},
+		{"trailing data", "application/json", `{"message":"hi"}{"message":"again"}`, http.StatusBadRequest, "request body must contain a single JSON value"},
+		{"trailing garbage", "application/json", `{"message":"hi"} x`, http.StatusBadRequest, "request body must contain a single JSON value"},
+		{"too large", "application/json", `{"message":"` + strings.Repeat("a", DefaultMaxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, "request body exceeds 1048576 bytes"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			var p payload
+			err := DecodeJSON(httptest.NewRecorder(), req, &p)
+			if tc.want == http.StatusOK {
+				if err != nil || p.Message != "hi" {
+					t.Fatalf("err=%v payload=%+v", err, p)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			if got := DecodeStatus(err); got != tc.want {
+				t.Fatalf("status=%d want %d", got, tc.want)
+			}
+			if err.Error() != tc.wantMsg {
+				t.Fatalf("msg=%q want %q", err.Error(), tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestRouteMaxBodyBytes(t *testing.T) {
+	rt := NewRouter(Route{
+		Method:       http.MethodPost,
+		Path:         "/echo",
+		MaxBodyBytes: 16,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var v map[string]string
+			if err := DecodeJSON(w, r, &v); err != nil {
+				Error(w, r, err.Error(), DecodeStatus(err))
+			}
+		}),
+	})
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"m":"short"}`, http.StatusOK},
+		{`{"m":"this is longer than sixteen bytes"}`, http.StatusRequestEntityTooLarge},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("body %q: status=%d want %d", tc.body, rec.Code, tc.want)
+		}
+		if tc.want == http.StatusRequestEntityTooLarge && !strings.Contains(rec.Body.String(), "exceeds 16 bytes") {
+			t.Fatalf("body=%q", rec.Body.String())
+		}
+	}
+}
+
+func TestRouteRaisesMaxBodyBytes(t *testing.T) {
+	const limit = 2 << 20
+	rt := NewRouter(Route{
+		Method:       http.MethodPost,
+		Path:         "/upload",
+		MaxBodyBytes: limit,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var v map[string]string
+			if err := DecodeJSON(w, r, &v); err != nil {
+				Error(w, r, err.Error(), DecodeStatus(err))
+			}
+		}),
+	})
+	for _, tc := range []struct {
+		size int
+		want int
+	}{
+		{3 << 19, http.StatusOK}, // 1.5 MiB, above DefaultMaxBodyBytes
+		{5 << 19, http.StatusRequestEntityTooLarge},
+	} {
+		body := `{"m":"` + strings.Repeat("a", tc.size) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%d byte body: status=%d want %d: %s", len(body), rec.Code, tc.want, rec.Body)
+		}
+		if tc.want == http.StatusRequestEntityTooLarge && !strings.Contains(rec.Body.String(), "exceeds 2097152 bytes") {
+			t.Fatalf("body=%q", rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,11 @@
+module example.com/go-class/httpkit
+
+go 1.22.0
+
+require (
+	example.com/go-class/10 v0.0.0
+	github.com/andybalholm/brotli v1.2.0
+	github.com/golang-jwt/jwt/v5 v5.2.2
+)
+
+replace example.com/go-class/10 => ../10
@@ -0,0 +1,161 @@
+package httpkit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	contextdemo "example.com/go-class/10"
+)
+
+// ComponentKey is the attribute key used to select per-component log levels.
+const ComponentKey = "component"
+
+// LogOptions configures the JSON logger built by NewLogger.
+type LogOptions struct {
+	Output io.Writer // defaults to os.Stdout
+	Level  slog.Level
+	// Components overrides Level for loggers derived with With(ComponentKey, name).
+	Components map[string]slog.Level
+}
+
+// NewLogger returns a JSON logger with per-component levels and secret redaction.
+func NewLogger(opts LogOptions) *slog.Logger {
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	h := slog.NewJSONHandler(out, &slog.HandlerOptions{
+		// componentHandler does the filtering, so let everything through here.
+		Level:       slog.LevelDebug,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(&componentHandler{next: h, level: opts.Level, levels: opts.Components})
+}
+
+// ParseLogLevels parses a spec such as "info,chat=debug,http=warn".
+// A bare level sets the default; name=level pairs set component overrides.
+func ParseLogLevels(spec string) (LogOptions, error) {
+	opts := LogOptions{Level: slog.LevelInfo}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			value, name = name, ""
+		}
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+			return LogOptions{}, fmt.Errorf("log level %q: %w", part, err)
+		}
+		if name == "" {
+			opts.Level = lvl
+			continue
+		}
+		if opts.Components == nil {
+			opts.Components = make(map[string]slog.Level)
+		}
+		opts.Components[strings.TrimSpace(name)] = lvl
+	}
+	return opts, nil
+}
+
+// ComponentLogger tags logger with a component name; nil stays nil.
+func ComponentLogger(logger *slog.Logger, name string) *slog.Logger {
+	if logger == nil {
+		return nil
+	}
+	return logger.With(ComponentKey, name)
+}
+
+// componentHandler switches its minimum level when a component attr is attached.
+type componentHandler struct {
+	next   slog.Handler
+	level  slog.Level
+	levels map[string]slog.Level
+}
+
+func (h *componentHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level
+}
+
+// Handle adds the request id from ctx, so every *Context log call is correlated.
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := contextdemo.RequestID(ctx); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.next = h.next.WithAttrs(attrs)
+	for _, a := range attrs {
+		if a.Key != ComponentKey {
+			continue
+		}
+		if lvl, ok := h.levels[a.Value.String()]; ok {
+			c.level = lvl
+		}
+	}
+	return &c
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.next = h.next.WithGroup(name)
+	return &c
+}
+
+// sensitiveKeys are redacted wherever they appear as an attr key (case-insensitive,
+// "-" and "_" are equivalent, and a suffix such as "refresh_token" also matches).
+var sensitiveKeys = []string{"authorization", "password", "passwd", "secret", "token", "api_key", "apikey", "cookie", "set_cookie"}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+	return a
+}
+
+func isSensitiveKey(key string) bool {
+	k := strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	for _, s := range sensitiveKeys {
+		if k == s || strings.HasSuffix(k, "_"+s) {
+			return true
+		}
+	}
+	return false
+}
+
+type logAttrsKey struct{}
+
+// logAttrs collects attributes that inner handlers add to the access log line.
+type logAttrs struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// AddLogAttrs attaches attrs to the access log line of the current request.
+// It is a no-op outside LoggingMiddleware.
+func AddLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	la, ok := ctx.Value(logAttrsKey{}).(*logAttrs)
+	if !ok {
+		return
+	}
+	la.mu.Lock()
+	la.attrs = append(la.attrs, attrs...)
+	la.mu.Unlock()
+}
+
+func (la *logAttrs) list() []slog.Attr {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+	return la.attrs
+}
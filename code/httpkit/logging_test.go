@@ -0,0 +1,79 @@
+package httpkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggingMiddlewareJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(LogOptions{Output: &buf})
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddLogAttrs(r.Context(), slog.String("user", "alice"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	}), RequestIDMiddleware(), LoggingMiddleware(logger))
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("User-Agent", "test-agent")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log is not json: %v (%q)", err, buf.String())
+	}
+	want := map[string]any{
+		"status":     float64(http.StatusCreated),
+		"bytes":      float64(5),
+		"request_id": "req-1",
+		"user":       "alice",
+		"remote_ip":  "192.0.2.1",
+		"user_agent": "test-agent",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Fatalf("%s=%v want %v", k, line[k], v)
+		}
+	}
+}
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(LogOptions{Output: &buf})
+	logger.Info("login", "Authorization", "Bearer abc", "password", "123", "refresh_token", "xyz", "username", "alice")
+	out := buf.String()
+	for _, secret := range []string{"Bearer abc", `"123"`, "xyz"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("secret %q leaked: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"username":"alice"`) {
+		t.Fatalf("non-sensitive field missing: %s", out)
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	opts, err := ParseLogLevels("warn,chat=debug")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var buf bytes.Buffer
+	opts.Output = &buf
+	logger := NewLogger(opts)
+
+	logger.With(ComponentKey, "http").Info("dropped")
+	logger.With(ComponentKey, "chat").Debug("kept")
+	out := buf.String()
+	if strings.Contains(out, "dropped") || !strings.Contains(out, "kept") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	if _, err := ParseLogLevels("chat=loud"); err == nil {
+		t.Fatalf("expected error for unknown level")
+	}
+}
@@ -0,0 +1,85 @@
+// Package httpkit holds the HTTP middleware shared by the chapter 13, 14 and 17
+// servers: request ids, structured logging, panic recovery, JWT auth and
+// security headers, plus the ResponseRecorder they build on.
+package httpkit
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps a handler; every constructor in this package returns one.
+type Middleware = func(http.Handler) http.Handler
+
+// LoggingMiddleware writes one JSON line per request with method, path, status,
+// bytes, duration, time to first byte, remote ip and user agent; NewLogger adds
+// the request id. A request whose handler panics, such as the
+// http.ErrAbortHandler RecoverMiddleware raises for a truncated body, is still
+// logged at error level with aborted=true before the panic continues.
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if logger == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			la := &logAttrs{}
+			r = r.WithContext(context.WithValue(r.Context(), logAttrsKey{}, la))
+			ww, rec := NewResponseRecorder(w)
+			defer func() {
+				v := recover()
+				logRequest(logger, r, rec, la, start, v != nil)
+				if v != nil {
+					panic(v)
+				}
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+func logRequest(logger *slog.Logger, r *http.Request, rec *ResponseRecorder, la *logAttrs, start time.Time, aborted bool) {
+	status := rec.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Int64("bytes", rec.BytesWritten()),
+		slog.Duration("duration", time.Since(start)),
+		slog.Duration("ttfb", rec.TimeToFirstByte()),
+		slog.String("remote_ip", remoteIP(r)),
+		slog.String("user_agent", r.UserAgent()),
+	}
+	attrs = append(attrs, la.list()...)
+	level := slog.LevelInfo
+	if aborted {
+		attrs = append(attrs, slog.Bool("aborted", true))
+	}
+	if aborted || status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logger.LogAttrs(r.Context(), level, "request", attrs...)
+}
+
+// Chain applies middlewares from left to right.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,165 @@
+package httpkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"example.com/go-class/httpkit/validate"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details.
+const ProblemContentType = "application/problem+json"
+
+// StatusClientClosedRequest is the non-standard 499 nginx logs when the
+// client went away before the response; WriteError uses it for
+// context.Canceled.
+const StatusClientClosedRequest = 499
+
+// ProblemTypeValidation identifies problems that carry per-field Errors.
+const ProblemTypeValidation = "urn:go-class:problem:validation"
+
+// Sentinel errors that WriteError maps to statuses. Wrap them to add context,
+// e.g. fmt.Errorf("conversation %s: %w", id, ErrNotFound); the wrapped message
+// becomes the problem detail, so only wrap text that is safe to show clients.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnavailable  = errors.New("service unavailable")
+)
+
+var sentinelStatus = []struct {
+	err    error
+	status int
+}{
+	{ErrBadRequest, http.StatusBadRequest},
+	{ErrUnauthorized, http.StatusUnauthorized},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrNotFound, http.StatusNotFound},
+	{ErrConflict, http.StatusConflict},
+	{ErrUnavailable, http.StatusServiceUnavailable},
+}
+
+// Problem is an RFC 9457 problem details object. Err is the internal cause: it
+// goes to the access log as "error" and is never sent to the client.
+type Problem struct {
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Status    int             `json:"status"`
+	Detail    string          `json:"detail,omitempty"`
+	Instance  string          `json:"instance,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	Errors    validate.Errors `json:"errors,omitempty"`
+	Err       error           `json:"-"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return http.StatusText(p.Status)
+}
+
+func (p *Problem) Unwrap() error { return p.Err }
+
+// complete fills the defaults and the per-request members.
+func (p *Problem) complete(r *http.Request) {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = RequestID(r.Context())
+	}
+	if p.Err != nil {
+		AddLogAttrs(r.Context(), slog.String("error", p.Err.Error()))
+	}
+}
+
+// WriteProblem replies with p as application/problem+json. For
+// StatusClientClosedRequest only the status is recorded: nobody is left to
+// read a body.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.complete(r)
+	if p.Status == StatusClientClosedRequest {
+		w.WriteHeader(p.Status)
+		return
+	}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", ProblemContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// WriteError replies with the problem for err: *Problem as is, DecodeJSON and
+// validate.Struct errors as 4xx with their messages, sentinel errors with
+// their status, context.DeadlineExceeded as 504 and context.Canceled, the
+// client going away, as StatusClientClosedRequest. Anything else is a 500
+// whose detail stays in the log.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	WriteProblem(w, r, ProblemFor(err))
+}
+
+// ProblemFor converts err to a Problem as described for WriteError.
+func ProblemFor(err error) *Problem {
+	var (
+		p      *Problem
+		de     *DecodeError
+		fields validate.Errors
+	)
+	switch {
+	case errors.As(err, &p):
+		cp := *p
+		return &cp
+	case errors.As(err, &de):
+		return &Problem{Status: de.Status, Detail: de.Msg, Err: de.Err}
+	case errors.As(err, &fields):
+		return &Problem{
+			Type:   ProblemTypeValidation,
+			Title:  "Validation failed",
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("%d field(s) failed validation", len(fields)),
+			Errors: fields,
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Problem{Status: http.StatusGatewayTimeout, Detail: "request timed out", Err: err}
+	case errors.Is(err, context.Canceled):
+		return &Problem{Status: StatusClientClosedRequest, Title: "Client Closed Request", Err: err}
+	}
+	for _, s := range sentinelStatus {
+		if errors.Is(err, s.err) {
+			return &Problem{Status: s.status, Detail: err.Error()}
+		}
+	}
+	return &Problem{Status: http.StatusInternalServerError, Err: err}
+}
+
+// Error replies with a problem whose detail is msg. msg is shown to clients.
+func Error(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	WriteProblem(w, r, &Problem{Status: code, Detail: msg})
+}
+
+// SSEError reports p on an event stream whose headers are already sent, as
+// "event: error" with the problem JSON as data, and flushes.
+func SSEError(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.complete(r)
+	data, _ := json.Marshal(p)
+	_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+	_ = http.NewResponseController(w).Flush()
+}
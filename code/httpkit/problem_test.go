@@ -0,0 +1,95 @@
+package httpkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contextdemo "example.com/go-class/10"
+	"example.com/go-class/httpkit/validate"
+)
+
+func TestWriteError(t *testing.T) {
+	upstream := errors.New("dial tcp 10.0.0.7:443: connection refused")
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"validation", validate.Struct(struct {
+			Message string `json:"message" validate:"required"`
+			Email   string `json:"email" validate:"email"`
+		}{Email: "nope"}), `{"type":"urn:go-class:problem:validation","title":"Validation failed","status":400,` +
+			`"detail":"2 field(s) failed validation","instance":"/echo","request_id":"req-7",` +
+			`"errors":[{"field":"message","reason":"is required"},{"field":"email","reason":"must be a valid email address"}]}`},
+		{"decode", &DecodeError{Status: http.StatusUnsupportedMediaType, Msg: "Content-Type must be application/json"},
+			`{"type":"about:blank","title":"Unsupported Media Type","status":415,"detail":"Content-Type must be application/json","instance":"/echo","request_id":"req-7"}`},
+		{"wrapped sentinel", fmt.Errorf("conversation 42: %w", ErrNotFound),
+			`{"type":"about:blank","title":"Not Found","status":404,"detail":"conversation 42: not found","instance":"/echo","request_id":"req-7"}`},
+		{"problem", fmt.Errorf("chat: %w", &Problem{Status: http.StatusBadGateway, Detail: "chat provider unavailable", Err: upstream}),
+			`{"type":"about:blank","title":"Bad Gateway","status":502,"detail":"chat provider unavailable","instance":"/echo","request_id":"req-7"}`},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded),
+			`{"type":"about:blank","title":"Gateway Timeout","status":504,"detail":"request timed out","instance":"/echo","request_id":"req-7"}`},
+		{"unknown stays internal", fmt.Errorf("query: %w", upstream),
+			`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/echo","request_id":"req-7"}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/echo?token=secret", nil)
+			req = req.WithContext(contextdemo.WithRequestID(req.Context(), "req-7"))
+			rec := httptest.NewRecorder()
+			WriteError(rec, req, tc.err)
+			if ct := rec.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Fatalf("Content-Type=%q", ct)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tc.want {
+				t.Fatalf("body=%s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteErrorLogsCause(t *testing.T) {
+	var logs bytes.Buffer
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, errors.New("pq: password authentication failed"))
+	}), LoggingMiddleware(NewLogger(LogOptions{Output: &logs})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rec.Body.String(), "pq:") {
+		t.Fatalf("cause leaked to client: %s", rec.Body.String())
+	}
+	if !strings.Contains(logs.String(), `"error":"pq: password authentication failed"`) {
+		t.Fatalf("cause missing from access log: %s", logs.String())
+	}
+}
+
+func TestWriteErrorClientGone(t *testing.T) {
+	var logs bytes.Buffer
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, fmt.Errorf("query: %w", context.Canceled))
+	}), LoggingMiddleware(NewLogger(LogOptions{Output: &logs})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != StatusClientClosedRequest || rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Fatalf("status=%d type=%q body=%q want a bare 499", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if !strings.Contains(logs.String(), `"status":499`) || !strings.Contains(logs.String(), `"error":"query: context canceled"`) {
+		t.Fatalf("access log=%s", logs.String())
+	}
+}
+
+func TestSSEError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SSEError(rec, httptest.NewRequest(http.MethodPost, "/chat", nil), &Problem{Status: http.StatusBadGateway, Err: io.ErrUnexpectedEOF})
+	want := "event: error\ndata: {\"type\":\"about:blank\",\"title\":\"Bad Gateway\",\"status\":502,\"instance\":\"/chat\"}\n\n"
+	if rec.Body.String() != want || !rec.Flushed {
+		t.Fatalf("body=%q flushed=%v", rec.Body.String(), rec.Flushed)
+	}
+}
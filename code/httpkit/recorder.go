@@ -0,0 +1,165 @@
+package httpkit
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseRecorder captures the status, body size and time to first byte of a
+// response while passing everything through to the wrapped writer.
+// Use NewResponseRecorder to get a writer that keeps the optional interfaces
+// (http.Flusher, http.Hijacker, io.ReaderFrom) of the original one.
+type ResponseRecorder struct {
+	w         http.ResponseWriter
+	start     time.Time
+	firstByte time.Time
+	status    int
+	bytes     int64
+	hijacked  bool
+}
+
+// NewResponseRecorder wraps w. The returned writer implements http.Flusher,
+// http.Hijacker and io.ReaderFrom exactly when w does, so type assertions such
+// as w.(http.Flusher) in streaming handlers keep their meaning, and it unwraps
+// for http.ResponseController.
+func NewResponseRecorder(w http.ResponseWriter) (http.ResponseWriter, *ResponseRecorder) {
+	rec := &ResponseRecorder{w: w, start: time.Now()}
+	_, f := w.(http.Flusher)
+	_, h := w.(http.Hijacker)
+	_, rf := w.(io.ReaderFrom)
+	switch {
+	case f && h && rf:
+		return struct {
+			*ResponseRecorder
+			flushRecorder
+			hijackRecorder
+			readFromRecorder
+		}{rec, flushRecorder{rec}, hijackRecorder{rec}, readFromRecorder{rec}}, rec
+	case f && h:
+		return struct {
+			*ResponseRecorder
+			flushRecorder
+			hijackRecorder
+		}{rec, flushRecorder{rec}, hijackRecorder{rec}}, rec
+	case f && rf:
+		return struct {
+			*ResponseRecorder
+			flushRecorder
+			readFromRecorder
+		}{rec, flushRecorder{rec}, readFromRecorder{rec}}, rec
+	case h && rf:
+		return struct {
+			*ResponseRecorder
+			hijackRecorder
+			readFromRecorder
+		}{rec, hijackRecorder{rec}, readFromRecorder{rec}}, rec
+	case f:
+		return struct {
+			*ResponseRecorder
+			flushRecorder
+		}{rec, flushRecorder{rec}}, rec
+	case h:
+		return struct {
+			*ResponseRecorder
+			hijackRecorder
+		}{rec, hijackRecorder{rec}}, rec
+	case rf:
+		return struct {
+			*ResponseRecorder
+			readFromRecorder
+		}{rec, readFromRecorder{rec}}, rec
+	}
+	return rec, rec
+}
+
+// Header implements http.ResponseWriter.
+func (r *ResponseRecorder) Header() http.Header { return r.w.Header() }
+
+// WriteHeader implements http.ResponseWriter. Informational 1xx codes other
+// than 101 are forwarded without being recorded as the final status.
+func (r *ResponseRecorder) WriteHeader(code int) {
+	if r.status == 0 && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		r.status = code
+		r.markFirstByte()
+	}
+	r.w.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (r *ResponseRecorder) Write(b []byte) (int, error) {
+	r.implicitOK()
+	n, err := r.w.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *ResponseRecorder) Unwrap() http.ResponseWriter { return r.w }
+
+// Status returns the response status; 200 if the handler wrote a body without
+// calling WriteHeader, 0 if nothing was written yet.
+func (r *ResponseRecorder) Status() int { return r.status }
+
+// BytesWritten returns the number of body bytes written.
+func (r *ResponseRecorder) BytesWritten() int64 { return r.bytes }
+
+// WroteHeader reports whether the status line has been sent.
+func (r *ResponseRecorder) WroteHeader() bool { return r.status != 0 }
+
+// Hijacked reports whether the connection was taken over by the handler.
+func (r *ResponseRecorder) Hijacked() bool { return r.hijacked }
+
+// TimeToFirstByte returns the time from wrapping to the first header or body
+// write, or 0 if nothing was written.
+func (r *ResponseRecorder) TimeToFirstByte() time.Duration {
+	if r.firstByte.IsZero() {
+		return 0
+	}
+	return r.firstByte.Sub(r.start)
+}
+
+func (r *ResponseRecorder) implicitOK() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+		r.markFirstByte()
+	}
+}
+
+func (r *ResponseRecorder) markFirstByte() {
+	if r.firstByte.IsZero() {
+		r.firstByte = time.Now()
+	}
+}
+
+type flushRecorder struct{ r *ResponseRecorder }
+
+func (f flushRecorder) Flush() {
+	f.r.implicitOK()
+	f.r.w.(http.Flusher).Flush()
+}
+
+type hijackRecorder struct{ r *ResponseRecorder }
+
+func (h hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.r.w.(http.Hijacker).Hijack()
+	if err == nil {
+		h.r.hijacked = true
+		if h.r.status == 0 {
+			h.r.status = http.StatusSwitchingProtocols
+			h.r.markFirstByte()
+		}
+	}
+	return conn, rw, err
+}
+
+type readFromRecorder struct{ r *ResponseRecorder }
+
+func (rf readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	rf.r.implicitOK()
+	n, err := rf.r.w.(io.ReaderFrom).ReadFrom(src)
+	rf.r.bytes += n
+	return n, err
+}
@@ -0,0 +1,90 @@
+package httpkit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type plainWriter struct {
+	h    http.Header
+	code int
+	body bytes.Buffer
+}
+
+func (p *plainWriter) Header() http.Header { return p.h }
+
+func (p *plainWriter) WriteHeader(code int) { p.code = code }
+
+func (p *plainWriter) Write(b []byte) (int, error) { return p.body.Write(b) }
+
+func TestResponseRecorderKeepsInterfaces(t *testing.T) {
+	ww, rec := NewResponseRecorder(httptest.NewRecorder())
+	f, ok := ww.(http.Flusher)
+	if !ok {
+		t.Fatalf("flusher lost")
+	}
+	if _, ok := ww.(http.Hijacker); ok {
+		t.Fatalf("httptest recorder cannot hijack; wrapper must not claim to")
+	}
+	f.Flush()
+	if rec.Status() != http.StatusOK || rec.TimeToFirstByte() <= 0 {
+		t.Fatalf("flush should commit 200: status=%d ttfb=%v", rec.Status(), rec.TimeToFirstByte())
+	}
+
+	ww, _ = NewResponseRecorder(&plainWriter{h: http.Header{}})
+	if _, ok := ww.(http.Flusher); ok {
+		t.Fatalf("plain writer must not gain Flush")
+	}
+	if err := http.NewResponseController(ww).Flush(); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("controller flush err=%v want ErrNotSupported", err)
+	}
+}
+
+func TestResponseRecorderCounts(t *testing.T) {
+	pw := &plainWriter{h: http.Header{}}
+	ww, rec := NewResponseRecorder(pw)
+	ww.WriteHeader(http.StatusEarlyHints)
+	if rec.WroteHeader() {
+		t.Fatalf("1xx must not be recorded as final status")
+	}
+	ww.WriteHeader(http.StatusAccepted)
+	_, _ = ww.Write([]byte("abc"))
+	_, _ = io.WriteString(ww, "de")
+	if rec.Status() != http.StatusAccepted || rec.BytesWritten() != 5 || pw.body.String() != "abcde" {
+		t.Fatalf("status=%d bytes=%d body=%q", rec.Status(), rec.BytesWritten(), pw.body.String())
+	}
+}
+
+func TestResponseRecorderOverRealConn(t *testing.T) {
+	var hijacked, readFrom bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww, rec := NewResponseRecorder(w)
+		_, hijacked = ww.(http.Hijacker)
+		rf, ok := ww.(io.ReaderFrom)
+		readFrom = ok
+		if ok {
+			_, _ = rf.ReadFrom(strings.NewReader("streamed"))
+		}
+		if err := http.NewResponseController(ww).Flush(); err != nil {
+			t.Errorf("controller flush: %v", err)
+		}
+		if rec.BytesWritten() != int64(len("streamed")) {
+			t.Errorf("bytes=%d", rec.BytesWritten())
+		}
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !hijacked || !readFrom || string(body) != "streamed" {
+		t.Fatalf("hijacker=%v readerFrom=%v body=%q", hijacked, readFrom, body)
+	}
+}
@@ -0,0 +1,97 @@
+package httpkit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// PanicReport describes one recovered panic.
+type PanicReport struct {
+	Value     any
+	Stack     []byte
+	RequestID string
+	Method    string
+	Path      string
+	Time      time.Time
+}
+
+// PanicSink receives recovered panics, e.g. to forward them to an error tracker.
+type PanicSink interface {
+	ReportPanic(ctx context.Context, p PanicReport)
+}
+
+// PanicSinkFunc adapts a function to PanicSink.
+type PanicSinkFunc func(ctx context.Context, p PanicReport)
+
+// ReportPanic implements PanicSink.
+func (f PanicSinkFunc) ReportPanic(ctx context.Context, p PanicReport) { f(ctx, p) }
+
+// RecoverOptions configures RecoverMiddleware.
+type RecoverOptions struct {
+	Logger *slog.Logger
+	// Sinks receive every recovered panic in addition to the log.
+	Sinks []PanicSink
+}
+
+// RecoverMiddleware prevents panics from crashing the server. It logs the panic
+// with its stack and request id, hands it to every sink, and then answers in a
+// way that fits what was already sent:
+//   - nothing written: a 500 error body;
+//   - an SSE stream in progress: a final "event: error";
+//   - any other partial body: the connection is aborted, so the client sees a
+//     truncated response instead of a corrupted one.
+//
+// http.ErrAbortHandler is re-panicked untouched, as net/http expects; put
+// LoggingMiddleware outside RecoverMiddleware so the aborted request still
+// gets its access log line.
+func RecoverMiddleware(opts RecoverOptions) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww, rec := NewResponseRecorder(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				report := PanicReport{
+					Value:     v,
+					Stack:     debug.Stack(),
+					RequestID: RequestID(r.Context()),
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					Time:      time.Now(),
+				}
+				if opts.Logger != nil {
+					opts.Logger.ErrorContext(r.Context(), "panic recovered",
+						"panic", fmt.Sprint(v),
+						"method", r.Method,
+						"path", r.URL.Path,
+						"stack", string(report.Stack),
+					)
+				}
+				for _, s := range opts.Sinks {
+					s.ReportPanic(r.Context(), report)
+				}
+
+				switch {
+				case rec.Hijacked():
+				case !rec.WroteHeader():
+					WriteProblem(w, r, &Problem{Status: http.StatusInternalServerError})
+				case strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream"):
+					SSEError(w, r, &Problem{Status: http.StatusInternalServerError})
+				default:
+					panic(http.ErrAbortHandler)
+				}
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
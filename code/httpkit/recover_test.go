@@ -0,0 +1,111 @@
+package httpkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	panicHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+	h := Chain(panicHandler, RecoverMiddleware(RecoverOptions{Logger: NewLogger(LogOptions{Output: io.Discard})}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status=%d want 500", rec.Code)
+	}
+}
+
+func TestRecoverMiddlewareReportsStack(t *testing.T) {
+	var got PanicReport
+	sink := PanicSinkFunc(func(_ context.Context, p PanicReport) { got = p })
+	h := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}), RequestIDMiddleware(), RecoverMiddleware(RecoverOptions{Sinks: []PanicSink{sink}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/p", nil)
+	req.Header.Set(RequestIDHeader, "req-7")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "req-7") {
+		t.Fatalf("status=%d body=%q", rec.Code, rec.Body.String())
+	}
+	if got.Value != "boom" || got.RequestID != "req-7" || got.Path != "/p" {
+		t.Fatalf("report=%+v", got)
+	}
+	if !strings.Contains(string(got.Stack), "TestRecoverMiddlewareReportsStack") {
+		t.Fatalf("stack does not point at the panic:\n%s", got.Stack)
+	}
+}
+
+func TestRecoverMiddlewareDuringSSE(t *testing.T) {
+	h := RecoverMiddleware(RecoverOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+		panic("mid-stream")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d, headers were already sent as 200", rec.Code)
+	}
+	want := "data: hello\n\nevent: error\ndata: {\"type\":\"about:blank\",\"title\":\"Internal Server Error\",\"status\":500,\"instance\":\"/\"}\n\n"
+	if rec.Body.String() != want {
+		t.Fatalf("body=%q want %q", rec.Body.String(), want)
+	}
+}
+
+func TestRecoverMiddlewareAbortsPartialBody(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"abort handler passes through": func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		},
+		"partial body is aborted": func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("half"))
+			panic("late")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if v := recover(); v != http.ErrAbortHandler {
+					t.Fatalf("recovered %v, want http.ErrAbortHandler", v)
+				}
+			}()
+			RecoverMiddleware(RecoverOptions{})(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	}
+}
+
+func TestRecoverMiddlewareAbortKeepsAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("half"))
+		panic("late")
+	}), LoggingMiddleware(NewLogger(LogOptions{Output: &buf})), RecoverMiddleware(RecoverOptions{}))
+
+	func() {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("recovered %v, want http.ErrAbortHandler", v)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/half", nil))
+	}()
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("no access log line for the aborted request: %v (%q)", err, buf.String())
+	}
+	if line["path"] != "/half" || line["bytes"] != float64(4) || line["aborted"] != true || line["level"] != "ERROR" {
+		t.Fatalf("access log=%v", line)
+	}
+}
@@ -0,0 +1,101 @@
+package httpkit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	contextdemo "example.com/go-class/10"
+)
+
+// RequestIDHeader carries the request id in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware reuses a well-formed incoming X-Request-ID, falls back to
+// the trace id of a W3C traceparent header, and otherwise generates a new id.
+// The id is stored with contextdemo.WithRequestID and echoed in the response.
+func RequestIDMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := incomingRequestID(r)
+			if id == "" {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(contextdemo.WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// RequestID returns the request id stored in ctx by RequestIDMiddleware, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := contextdemo.RequestID(ctx)
+	return id
+}
+
+// RequestIDTransport forwards the request id in the outgoing request's context
+// to upstream services, so one call can be traced end to end. A nil base means
+// http.DefaultTransport.
+func RequestIDTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &requestIDTransport{base: base}
+}
+
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id := RequestID(req.Context()); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func incomingRequestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	return traceIDFromParent(r.Header.Get("traceparent"))
+}
+
+// validRequestID accepts short ids made of safe characters only, so a client
+// cannot inject arbitrary text into logs or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// traceIDFromParent extracts the trace id from "00-<trace-id>-<parent-id>-<flags>".
+func traceIDFromParent(tp string) string {
+	parts := strings.Split(strings.TrimSpace(tp), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+	traceID := strings.ToLower(parts[1])
+	if _, err := hex.DecodeString(traceID); err != nil || traceID == strings.Repeat("0", 32) {
+		return ""
+	}
+	return traceID
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,81 @@
+package httpkit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	contextdemo "example.com/go-class/10"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = contextdemo.RequestID(r.Context())
+	}), RequestIDMiddleware())
+
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{"incoming", "X-Request-ID", "abc-123", "abc-123"},
+		{"traceparent", "traceparent", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"invalid is replaced", "X-Request-ID", "bad id\n", ""},
+		{"generated", "", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if seen == "" || rec.Header().Get(RequestIDHeader) != seen {
+				t.Fatalf("ctx id=%q header=%q", seen, rec.Header().Get(RequestIDHeader))
+			}
+			if tc.want != "" && seen != tc.want {
+				t.Fatalf("id=%q want %q", seen, tc.want)
+			}
+			if tc.want == "" && len(seen) != 32 {
+				t.Fatalf("generated id=%q want 32 hex chars", seen)
+			}
+		})
+	}
+}
+
+func TestErrorIncludesRequestID(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, "nope", http.StatusTeapot)
+	}), RequestIDMiddleware())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-9")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTeapot || !strings.Contains(rec.Body.String(), `"detail":"nope","instance":"/","request_id":"req-9"`) {
+		t.Fatalf("status=%d body=%q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	var got string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(RequestIDHeader)
+	}))
+	defer upstream.Close()
+
+	ctx := contextdemo.WithRequestID(context.Background(), "req-up")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	resp, err := (&http.Client{Transport: RequestIDTransport(nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if got != "req-up" {
+		t.Fatalf("upstream saw %q want req-up", got)
+	}
+}
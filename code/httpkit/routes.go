@@ -0,0 +1,168 @@
+package httpkit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Route is one entry of a server's route table.
+type Route struct {
+	// Method is an HTTP method such as "GET"; empty matches every method.
+	Method string
+	// Path is a Go 1.22 ServeMux path pattern, e.g. "/conversations/{id}".
+	Path    string
+	Handler http.Handler
+	// Public routes skip BearerAuthMiddleware (see Router.IsPublic).
+	Public bool
+	// Summary is a one-line description for generated docs.
+	Summary string
+	// MaxBodyBytes caps the request body, above or below
+	// DefaultMaxBodyBytes; reading past it fails and DecodeJSON answers 413.
+	// Zero leaves DefaultMaxBodyBytes to DecodeJSON.
+	MaxBodyBytes int64
+}
+
+// Pattern returns the ServeMux pattern, e.g. "POST /chat".
+func (rt Route) Pattern() string {
+	if rt.Method == "" {
+		return rt.Path
+	}
+	return rt.Method + " " + rt.Path
+}
+
+// Router serves a route table with http.ServeMux method patterns. Unsupported
+// methods get 405 and an Allow header, OPTIONS is answered automatically, and
+// the table is kept for introspection.
+type Router struct {
+	// NotFound, if set, handles requests whose path matches no route instead
+	// of the default 404 problem, e.g. to serve a single-page app's index.
+	NotFound http.Handler
+
+	mux     *http.ServeMux
+	routes  []Route
+	byPat   map[string]Route
+	methods []string
+}
+
+// NewRouter registers routes on a fresh ServeMux. It panics on conflicting
+// patterns, like http.ServeMux does.
+func NewRouter(routes ...Route) *Router {
+	rt := &Router{mux: http.NewServeMux(), byPat: make(map[string]Route)}
+	for _, r := range routes {
+		rt.Handle(r)
+	}
+	return rt
+}
+
+// Handle adds one route.
+func (rt *Router) Handle(r Route) {
+	h := r.Handler
+	if limit := r.MaxBodyBytes; limit > 0 {
+		next := h
+		h = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.Body = http.MaxBytesReader(w, req.Body, limit)
+			next.ServeHTTP(w, req.WithContext(withMaxBodyBytes(req.Context(), limit)))
+		})
+	}
+	rt.mux.Handle(r.Pattern(), h)
+	rt.routes = append(rt.routes, r)
+	rt.byPat[r.Pattern()] = r
+	rt.addMethod(r.Method)
+	if r.Method == http.MethodGet {
+		rt.addMethod(http.MethodHead)
+	}
+}
+
+func (rt *Router) addMethod(m string) {
+	if m == "" {
+		return
+	}
+	for _, have := range rt.methods {
+		if have == m {
+			return
+		}
+	}
+	rt.methods = append(rt.methods, m)
+}
+
+// Routes returns a copy of the route table in registration order.
+func (rt *Router) Routes() []Route {
+	return append([]Route(nil), rt.routes...)
+}
+
+// ServeHTTP answers OPTIONS with 204 and an Allow header unless a route handles
+// OPTIONS itself, renders the ServeMux's 404 and 405 as problem details, and
+// delegates everything else to the ServeMux.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern == "" {
+		allow := rt.Allowed(r)
+		switch {
+		case len(allow) == 0 && rt.NotFound != nil:
+			rt.NotFound.ServeHTTP(w, r)
+		case len(allow) == 0:
+			WriteProblem(w, r, &Problem{Status: http.StatusNotFound, Detail: "no route for " + r.URL.Path})
+		case r.Method == http.MethodOptions:
+			w.Header().Set("Allow", strings.Join(append(allow, http.MethodOptions), ", "))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			WriteProblem(w, r, &Problem{Status: http.StatusMethodNotAllowed, Detail: r.Method + " is not allowed here"})
+		}
+		return
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Allowed lists the methods registered for r's path, sorted like the
+// ServeMux's own Allow header.
+func (rt *Router) Allowed(r *http.Request) []string {
+	var allow []string
+	probe := r.Clone(r.Context())
+	for _, m := range rt.methods {
+		probe.Method = m
+		if _, pattern := rt.mux.Handler(probe); pattern != "" {
+			allow = append(allow, m)
+		}
+	}
+	sort.Strings(allow)
+	return allow
+}
+
+// Match returns the route that would serve r.
+func (rt *Router) Match(r *http.Request) (Route, bool) {
+	_, pattern := rt.mux.Handler(r)
+	route, ok := rt.byPat[pattern]
+	return route, ok
+}
+
+// IsPublic reports whether r targets a Public route. Requests no route matches
+// are public too: Router answers them with 404, 405 or an automatic OPTIONS
+// response without running any route handler, so Router.NotFound must not
+// need auth either. Use it as AuthOptions.Public.
+func (rt *Router) IsPublic(r *http.Request) bool {
+	route, ok := rt.Match(r)
+	return !ok || route.Public
+}
+
+// WriteRoutes renders routes as a Markdown table for API docs.
+func WriteRoutes(w io.Writer, routes []Route) error {
+	if _, err := fmt.Fprintln(w, "| Method | Path | Auth | Summary |\n|---|---|---|---|"); err != nil {
+		return err
+	}
+	for _, r := range routes {
+		method, auth := r.Method, "Bearer"
+		if method == "" {
+			method = "*"
+		}
+		if r.Public {
+			auth = "public"
+		}
+		if _, err := fmt.Fprintf(w, "| %s | `%s` | %s | %s |\n", method, r.Path, auth, r.Summary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
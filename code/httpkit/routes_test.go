@@ -0,0 +1,89 @@
+package httpkit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	ok := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body + r.PathValue("id")))
+		})
+	}
+	rt := NewRouter(
+		Route{Method: http.MethodGet, Path: "/conversations/{id}", Handler: ok("get ")},
+		Route{Method: http.MethodDelete, Path: "/conversations/{id}", Handler: ok("delete ")},
+		Route{Method: http.MethodPost, Path: "/chat", Handler: ok("chat")},
+		Route{Method: http.MethodOptions, Path: "/custom", Handler: ok("custom options")},
+	)
+
+	cases := []struct {
+		name      string
+		method    string
+		path      string
+		want      int
+		wantBody  string
+		wantAllow string
+	}{
+		{"path value", http.MethodGet, "/conversations/42", http.StatusOK, "get 42", ""},
+		{"second method", http.MethodDelete, "/conversations/42", http.StatusOK, "delete 42", ""},
+		{"wrong method", http.MethodPost, "/conversations/42", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD"},
+		{"auto options", http.MethodOptions, "/conversations/42", http.StatusNoContent, "", "DELETE, GET, HEAD, OPTIONS"},
+		{"auto options single", http.MethodOptions, "/chat", http.StatusNoContent, "", "POST, OPTIONS"},
+		{"explicit options", http.MethodOptions, "/custom", http.StatusOK, "custom options", ""},
+		{"unknown path", http.MethodOptions, "/missing", http.StatusNotFound, "", ""},
+		{"unknown path get", http.MethodGet, "/missing", http.StatusNotFound, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rt.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+			if rec.Code != tc.want {
+				t.Fatalf("status=%d want %d", rec.Code, tc.want)
+			}
+			if tc.wantBody != "" && rec.Body.String() != tc.wantBody {
+				t.Fatalf("body=%q want %q", rec.Body.String(), tc.wantBody)
+			}
+			if got := rec.Header().Get("Allow"); got != tc.wantAllow {
+				t.Fatalf("Allow=%q want %q", got, tc.wantAllow)
+			}
+			if ct := rec.Header().Get("Content-Type"); tc.want >= 400 && ct != ProblemContentType {
+				t.Fatalf("Content-Type=%q want problem details", ct)
+			}
+		})
+	}
+}
+
+func TestRouterIntrospection(t *testing.T) {
+	h := http.NotFoundHandler()
+	rt := NewRouter(
+		Route{Method: http.MethodPost, Path: "/login", Handler: h, Public: true, Summary: "issue a token"},
+		Route{Method: http.MethodPost, Path: "/chat", Handler: h, Summary: "stream a reply"},
+	)
+	if got := len(rt.Routes()); got != 2 {
+		t.Fatalf("routes=%d want 2", got)
+	}
+	if !rt.IsPublic(httptest.NewRequest(http.MethodPost, "/login", nil)) {
+		t.Fatalf("POST /login should be public")
+	}
+	if rt.IsPublic(httptest.NewRequest(http.MethodPost, "/chat", nil)) {
+		t.Fatalf("POST /chat should need auth")
+	}
+	if !rt.IsPublic(httptest.NewRequest(http.MethodGet, "/chat", nil)) {
+		t.Fatalf("GET /chat matches no route and should reach the 405 without auth")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRoutes(&buf, rt.Routes()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for _, want := range []string{"| POST | `/login` | public | issue a token |", "| POST | `/chat` | Bearer | stream a reply |"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("docs missing %q:\n%s", want, buf.String())
+		}
+	}
+}
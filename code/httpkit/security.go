@@ -0,0 +1,307 @@
+package httpkit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SecurityOptions is the response header policy applied by SecurityHeaders.
+// The zero value sends nosniff, X-Frame-Options: DENY and
+// Content-Security-Policy: default-src 'self'; DefaultSecurityOptions returns
+// a fuller policy.
+type SecurityOptions struct {
+	CSP  CSP
+	HSTS HSTS
+	// FrameOptions defaults to DENY.
+	FrameOptions string
+	// ReferrerPolicy, e.g. "strict-origin-when-cross-origin"; empty omits it.
+	ReferrerPolicy string
+	// PermissionsPolicy, e.g. "camera=(), microphone=()"; empty omits it.
+	PermissionsPolicy string
+	// CrossOriginOpenerPolicy, e.g. "same-origin"; empty omits it.
+	CrossOriginOpenerPolicy string
+	// CrossOriginEmbedderPolicy, e.g. "require-corp"; empty omits it. It blocks
+	// cross-origin assets that lack CORS or CORP headers, so enable it with care.
+	CrossOriginEmbedderPolicy string
+}
+
+// CSP describes a Content-Security-Policy.
+type CSP struct {
+	// Directives maps a directive to its sources, e.g.
+	// "script-src": {"'self'", "https://cdn.jsdelivr.net"}. Nil means
+	// default-src 'self'.
+	Directives map[string][]string
+	// Nonce adds a fresh 'nonce-…' source to script-src and style-src on every
+	// request; templates read it with CSPNonce.
+	Nonce bool
+	// ReportOnly sends Content-Security-Policy-Report-Only: violations are
+	// reported but not blocked.
+	ReportOnly bool
+	// ReportURI receives violation reports, e.g. "/csp-report" served by
+	// CSPReportHandler.
+	ReportURI string
+}
+
+// HSTS configures Strict-Transport-Security, which is only sent over TLS.
+type HSTS struct {
+	// MaxAge of zero disables the header.
+	MaxAge            time.Duration
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+// DefaultSecurityOptions is a strict policy for an app that serves its own
+// scripts and styles: nonce-based CSP, six months of HSTS, no referrer leaks
+// across origins and no powerful browser features.
+func DefaultSecurityOptions() SecurityOptions {
+	return SecurityOptions{
+		CSP: CSP{
+			Directives: map[string][]string{
+				"default-src":     {"'self'"},
+				"script-src":      {"'self'"},
+				"style-src":       {"'self'"},
+				"img-src":         {"'self'", "data:"},
+				"connect-src":     {"'self'"},
+				"object-src":      {"'none'"},
+				"base-uri":        {"'self'"},
+				"frame-ancestors": {"'none'"},
+			},
+			Nonce: true,
+		},
+		HSTS:                    HSTS{MaxAge: 180 * 24 * time.Hour, IncludeSubDomains: true},
+		ReferrerPolicy:          "strict-origin-when-cross-origin",
+		PermissionsPolicy:       "camera=(), microphone=(), geolocation=(), payment=()",
+		CrossOriginOpenerPolicy: "same-origin",
+	}
+}
+
+type nonceKey struct{}
+
+// CSPNonce returns the nonce SecurityHeaders generated for this request, or ""
+// when CSP.Nonce is off.
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+// SecurityHeaders applies opts to every response.
+func SecurityHeaders(opts SecurityOptions) Middleware {
+	frameOptions := opts.FrameOptions
+	if frameOptions == "" {
+		frameOptions = "DENY"
+	}
+	cspHeader := "Content-Security-Policy"
+	if opts.CSP.ReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	staticCSP := opts.CSP.header("")
+	hsts := opts.HSTS.header()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", frameOptions)
+			if opts.CSP.Nonce {
+				nonce := newNonce()
+				h.Set(cspHeader, opts.CSP.header(nonce))
+				r = r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce))
+			} else {
+				h.Set(cspHeader, staticCSP)
+			}
+			if opts.CSP.ReportURI != "" {
+				h.Set("Reporting-Endpoints", `csp="`+opts.CSP.ReportURI+`"`)
+			}
+			if hsts != "" && r.TLS != nil {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			setIf(h, "Referrer-Policy", opts.ReferrerPolicy)
+			setIf(h, "Permissions-Policy", opts.PermissionsPolicy)
+			setIf(h, "Cross-Origin-Opener-Policy", opts.CrossOriginOpenerPolicy)
+			setIf(h, "Cross-Origin-Embedder-Policy", opts.CrossOriginEmbedderPolicy)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setIf(h http.Header, key, value string) {
+	if value != "" {
+		h.Set(key, value)
+	}
+}
+
+// header renders the policy with directives sorted for stable output.
+func (c CSP) header(nonce string) string {
+	directives := c.Directives
+	if directives == nil {
+		directives = map[string][]string{"default-src": {"'self'"}}
+	}
+	if nonce != "" {
+		withNonce := make(map[string][]string, len(directives)+2)
+		for k, v := range directives {
+			withNonce[k] = v
+		}
+		for _, d := range []string{"script-src", "style-src"} {
+			sources, ok := withNonce[d]
+			if !ok {
+				// A new directive replaces the default-src fallback, so keep it.
+				sources = directives["default-src"]
+			}
+			withNonce[d] = append(append([]string(nil), sources...), "'nonce-"+nonce+"'")
+		}
+		directives = withNonce
+	}
+	names := make([]string, 0, len(directives))
+	for k := range directives {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names)+2)
+	for _, k := range names {
+		parts = append(parts, strings.TrimSpace(k+" "+strings.Join(directives[k], " ")))
+	}
+	if c.ReportURI != "" {
+		parts = append(parts, "report-uri "+c.ReportURI, "report-to csp")
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (h HSTS) header() string {
+	if h.MaxAge <= 0 {
+		return ""
+	}
+	v := "max-age=" + strconv.FormatInt(int64(h.MaxAge.Seconds()), 10)
+	if h.IncludeSubDomains {
+		v += "; includeSubDomains"
+	}
+	if h.Preload {
+		v += "; preload"
+	}
+	return v
+}
+
+// newNonce returns 128 random bits in unpadded base64url. The URL alphabet
+// matters: html/template escapes '+' in attributes, which would make the
+// nonce in the markup differ from the one in the header.
+func newNonce() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// CSPViolation is one report received by CSPReportHandler, normalized from
+// either the legacy report-uri format or the Reporting API.
+type CSPViolation struct {
+	DocumentURI string
+	Directive   string
+	BlockedURI  string
+	Disposition string // "enforce" or "report"
+}
+
+const maxCSPReportBytes = 64 << 10
+
+// CSPReportHandler collects CSP violation reports and logs each one at warn
+// level under the "csp" component. Register it as a public POST route at the
+// CSP.ReportURI path.
+func CSPReportHandler(logger *slog.Logger) http.Handler {
+	logger = ComponentLogger(logger, "csp")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/csp-report", "application/reports+json", "application/json":
+		default:
+			Error(w, r, "unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				Error(w, r, "report too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			Error(w, r, "bad request", http.StatusBadRequest)
+			return
+		}
+		violations, err := parseCSPReports(body)
+		if err != nil {
+			Error(w, r, "bad request", http.StatusBadRequest)
+			return
+		}
+		if logger != nil {
+			for _, v := range violations {
+				logger.WarnContext(r.Context(), "csp violation",
+					slog.String("document_uri", v.DocumentURI),
+					slog.String("directive", v.Directive),
+					slog.String("blocked_uri", v.BlockedURI),
+					slog.String("disposition", v.Disposition),
+				)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func parseCSPReports(body []byte) ([]CSPViolation, error) {
+	var legacy struct {
+		Report *struct {
+			DocumentURI        string `json:"document-uri"`
+			ViolatedDirective  string `json:"violated-directive"`
+			EffectiveDirective string `json:"effective-directive"`
+			BlockedURI         string `json:"blocked-uri"`
+			Disposition        string `json:"disposition"`
+		} `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err == nil && legacy.Report != nil {
+		rep := legacy.Report
+		directive := rep.EffectiveDirective
+		if directive == "" {
+			directive = rep.ViolatedDirective
+		}
+		return []CSPViolation{{
+			DocumentURI: rep.DocumentURI,
+			Directive:   directive,
+			BlockedURI:  rep.BlockedURI,
+			Disposition: rep.Disposition,
+		}}, nil
+	}
+
+	var reports []struct {
+		Type string `json:"type"`
+		Body struct {
+			DocumentURL        string `json:"documentURL"`
+			EffectiveDirective string `json:"effectiveDirective"`
+			BlockedURL         string `json:"blockedURL"`
+			Disposition        string `json:"disposition"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, err
+	}
+	var out []CSPViolation
+	for _, rep := range reports {
+		if rep.Type != "csp-violation" {
+			continue
+		}
+		out = append(out, CSPViolation{
+			DocumentURI: rep.Body.DocumentURL,
+			Directive:   rep.Body.EffectiveDirective,
+			BlockedURI:  rep.Body.BlockedURL,
+			Disposition: rep.Body.Disposition,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,144 @@
+package httpkit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	h := SecurityHeaders(SecurityOptions{})(http.NotFoundHandler())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("missing nosniff")
+	}
+	if rec.Header().Get("X-Frame-Options") != "DENY" {
+		t.Fatalf("missing X-Frame-Options")
+	}
+	if got := rec.Header().Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Fatalf("csp=%q want default-src 'self'", got)
+	}
+}
+
+// The nonce is rendered into <script nonce="..."> by html/template and must
+// come out exactly as it appears in the CSP header.
+func TestCSPNonceSurvivesTemplateEscaping(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`<script nonce="{{.}}"></script>`))
+	for i := 0; i < 200; i++ {
+		n := newNonce()
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, n); err != nil {
+			t.Fatal(err)
+		}
+		if want := `<script nonce="` + n + `"></script>`; buf.String() != want {
+			t.Fatalf("nonce %q rendered as %q", n, buf.String())
+		}
+	}
+}
+
+func TestSecurityHeadersPolicy(t *testing.T) {
+	var nonces []string
+	h := SecurityHeaders(DefaultSecurityOptions())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, CSPNonce(r.Context()))
+	}))
+
+	var csp []string
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		csp = append(csp, rec.Header().Get("Content-Security-Policy"))
+		if rec.Header().Get("Strict-Transport-Security") != "" {
+			t.Fatalf("HSTS must not be sent over plain HTTP")
+		}
+		if rec.Header().Get("Referrer-Policy") != "strict-origin-when-cross-origin" ||
+			rec.Header().Get("Cross-Origin-Opener-Policy") != "same-origin" ||
+			rec.Header().Get("Permissions-Policy") == "" {
+			t.Fatalf("missing policy headers: %v", rec.Header())
+		}
+	}
+	if nonces[0] == "" || nonces[0] == nonces[1] {
+		t.Fatalf("nonces=%q want unique per request", nonces)
+	}
+	if !strings.Contains(csp[0], "script-src 'self' 'nonce-"+nonces[0]+"'") ||
+		!strings.Contains(csp[0], "style-src 'self' 'nonce-"+nonces[0]+"'") {
+		t.Fatalf("csp=%q missing nonce %q", csp[0], nonces[0])
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=15552000; includeSubDomains" {
+		t.Fatalf("hsts=%q", got)
+	}
+}
+
+func TestSecurityHeadersReportOnly(t *testing.T) {
+	h := SecurityHeaders(SecurityOptions{
+		CSP: CSP{
+			Directives: map[string][]string{"default-src": {"'self'"}, "img-src": {"https:"}},
+			Nonce:      true,
+			ReportOnly: true,
+			ReportURI:  "/csp-report",
+		},
+		HSTS: HSTS{MaxAge: time.Hour, Preload: true},
+	})(http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("Content-Security-Policy") != "" {
+		t.Fatalf("report-only must not enforce")
+	}
+	got := rec.Header().Get("Content-Security-Policy-Report-Only")
+	// script-src is created from default-src so the nonce does not drop 'self'.
+	for _, want := range []string{"default-src 'self'; img-src https:; script-src 'self' 'nonce-", "report-uri /csp-report; report-to csp"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("csp=%q missing %q", got, want)
+		}
+	}
+	if rec.Header().Get("Reporting-Endpoints") != `csp="/csp-report"` {
+		t.Fatalf("reporting endpoints=%q", rec.Header().Get("Reporting-Endpoints"))
+	}
+}
+
+func TestCSPReportHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+		wantLog     string
+	}{
+		{"legacy", "application/csp-report",
+			`{"csp-report":{"document-uri":"https://app/","violated-directive":"script-src","blocked-uri":"https://cdn.evil/x.js","disposition":"report"}}`,
+			http.StatusNoContent, `"blocked_uri":"https://cdn.evil/x.js"`},
+		{"reporting api", "application/reports+json",
+			`[{"type":"csp-violation","body":{"documentURL":"https://app/","effectiveDirective":"style-src-elem","blockedURL":"inline","disposition":"enforce"}}]`,
+			http.StatusNoContent, `"directive":"style-src-elem"`},
+		{"wrong content type", "text/plain", `{}`, http.StatusUnsupportedMediaType, ""},
+		{"malformed", "application/json", `{`, http.StatusBadRequest, ""},
+		{"too large", "application/json", `[` + strings.Repeat(" ", maxCSPReportBytes) + `]`, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := CSPReportHandler(NewLogger(LogOptions{Output: &buf}))
+			req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status=%d want %d", rec.Code, tc.want)
+			}
+			if tc.wantLog != "" && (!strings.Contains(buf.String(), tc.wantLog) || !strings.Contains(buf.String(), `"component":"csp"`)) {
+				t.Fatalf("log=%q want %s", buf.String(), tc.wantLog)
+			}
+		})
+	}
+}
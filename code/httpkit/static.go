@@ -0,0 +1,200 @@
+package httpkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Cache-Control values used by StaticFiles.
+const (
+	CacheImmutable = "public, max-age=31536000, immutable"
+	CacheNoCache   = "no-cache"
+)
+
+// StaticFiles serves a tree of static assets, such as an embed.FS, under a
+// URL prefix. Every file is read and hashed once at startup: responses carry
+// a content-hash ETag, and URL maps a path to a fingerprinted one
+// ("/assets/app.js" -> "/assets/app.1f2e3d4c5b.js") that is served with
+// CacheImmutable. The plain path still works but must be revalidated.
+// Precompressed name.br and name.gz variants from cmd/precompress are served
+// when accepted; each is decompressed at startup and must match its source,
+// so a stale variant is never served under the source's ETag. Directories and
+// unknown names get a 404 problem, never a listing.
+type StaticFiles struct {
+	prefix string
+	byName map[string]*staticFile // "app.js"
+	byURL  map[string]*staticFile // "app.1f2e3d4c5b.js"
+}
+
+type staticFile struct {
+	url      string
+	etag     string
+	ctype    string
+	body     []byte
+	variants map[string][]byte // coding -> precompressed body
+}
+
+// precompressedExt maps a coding to the file suffix cmd/precompress writes.
+var precompressedExt = map[string]string{"br": ".br", "gzip": ".gz"}
+
+// ErrStaleVariant means a precompressed variant does not decompress to its
+// source file; re-run cmd/precompress.
+var ErrStaleVariant = errors.New("precompressed variant does not match its source")
+
+// NewStaticFiles loads every file under fsys. prefix is the URL path the
+// tree is mounted at, e.g. "/assets/". It fails with ErrStaleVariant when a
+// precompressed variant is out of date.
+func NewStaticFiles(fsys fs.FS, prefix string) (*StaticFiles, error) {
+	s := &StaticFiles{
+		prefix: "/" + strings.Trim(prefix, "/") + "/",
+		byName: make(map[string]*staticFile),
+		byURL:  make(map[string]*staticFile),
+	}
+	if s.prefix == "//" {
+		s.prefix = "/"
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || isVariant(name) {
+			return err
+		}
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+		ext := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, ext) + "." + hash[:10] + ext
+		f := &staticFile{
+			url:      s.prefix + fingerprinted,
+			etag:     `"` + hash[:16] + `"`,
+			ctype:    mime.TypeByExtension(ext),
+			body:     body,
+			variants: make(map[string][]byte),
+		}
+		if f.ctype == "" {
+			f.ctype = http.DetectContentType(body)
+		}
+		for enc, suffix := range precompressedExt {
+			v, err := fs.ReadFile(fsys, name+suffix)
+			if err != nil {
+				continue
+			}
+			if err := checkVariant(enc, v, body); err != nil {
+				return fmt.Errorf("%s%s: %w", name, suffix, err)
+			}
+			f.variants[enc] = v
+		}
+		s.byName[name] = f
+		s.byURL[fingerprinted] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkVariant decompresses variant and compares it with body.
+func checkVariant(enc string, variant, body []byte) error {
+	var r io.Reader = brotli.NewReader(bytes.NewReader(variant))
+	if enc == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(variant))
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrStaleVariant, err)
+		}
+		r = zr
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStaleVariant, err)
+	}
+	if !bytes.Equal(got, body) {
+		return ErrStaleVariant
+	}
+	return nil
+}
+
+func isVariant(name string) bool {
+	for _, suffix := range precompressedExt {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns the fingerprinted URL for urlPath, e.g. "/assets/app.js", or
+// urlPath unchanged when it is not one of the files. Templates use it to
+// reference assets so that a new build busts the cache.
+func (s *StaticFiles) URL(urlPath string) string {
+	if f, ok := s.byName[strings.TrimPrefix(urlPath, s.prefix)]; ok {
+		return f.url
+	}
+	return urlPath
+}
+
+// ServeHTTP serves the file named by the request path.
+func (s *StaticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutPrefix(r.URL.Path, s.prefix)
+	if !ok {
+		WriteProblem(w, r, &Problem{Status: http.StatusNotFound, Detail: "no file " + r.URL.Path})
+		return
+	}
+	cache := CacheImmutable
+	f, ok := s.byURL[name]
+	if !ok {
+		cache = CacheNoCache
+		f, ok = s.byName[name]
+	}
+	if !ok {
+		WriteProblem(w, r, &Problem{Status: http.StatusNotFound, Detail: "no file " + r.URL.Path})
+		return
+	}
+
+	h := w.Header()
+	h.Set("Cache-Control", cache)
+	h.Set("Content-Type", f.ctype)
+	body, etag := f.body, f.etag
+	if len(f.variants) > 0 {
+		addVary(h, "Accept-Encoding")
+		for _, enc := range acceptedEncodings(r.Header.Get("Accept-Encoding")) {
+			if v, ok := f.variants[enc]; ok {
+				// Each coding is its own representation with its own tag.
+				body, etag = v, strings.TrimSuffix(f.etag, `"`)+"-"+enc+`"`
+				h.Set("Content-Encoding", enc)
+				break
+			}
+		}
+	}
+	h.Set("ETag", etag)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+}
+
+// SPAFallback serves index for browser navigations (GET or HEAD accepting
+// text/html) to paths without a file extension, so client-side routes survive
+// a reload. Everything else gets a 404 problem. Use it as Router.NotFound.
+func SPAFallback(index http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		navigation := (r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+			strings.Contains(r.Header.Get("Accept"), "text/html") && path.Ext(r.URL.Path) == ""
+		if !navigation {
+			WriteProblem(w, r, &Problem{Status: http.StatusNotFound, Detail: "no route for " + r.URL.Path})
+			return
+		}
+		index.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,176 @@
+package httpkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressed returns s encoded with enc ("br" or "gzip").
+func compressed(enc, s string) []byte {
+	var buf bytes.Buffer
+	var w interface {
+		Write([]byte) (int, error)
+		Close() error
+	} = gzip.NewWriter(&buf)
+	if enc == "br" {
+		w = brotli.NewWriter(&buf)
+	}
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func TestStaticFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":        {Data: []byte("console.log('hi')")},
+		"app.js.br":     {Data: compressed("br", "console.log('hi')")},
+		"css/site.css":  {Data: []byte("body{}")},
+		"css/empty.txt": {Data: nil},
+	}
+	s, err := NewStaticFiles(fsys, "/assets/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	appURL := s.URL("/assets/app.js")
+	if !strings.HasPrefix(appURL, "/assets/app.") || !strings.HasSuffix(appURL, ".js") || appURL == "/assets/app.js" {
+		t.Fatalf("URL=%q want a fingerprinted path", appURL)
+	}
+	if got := s.URL("/assets/missing.js"); got != "/assets/missing.js" {
+		t.Fatalf("unknown URL rewritten to %q", got)
+	}
+
+	cases := []struct {
+		name, path, accept string
+		want               int
+		wantCache, wantEnc string
+		wantBody           string
+	}{
+		{name: "fingerprinted", path: appURL, want: 200, wantCache: CacheImmutable, wantBody: "console.log('hi')"},
+		{name: "plain name revalidates", path: "/assets/app.js", want: 200, wantCache: CacheNoCache, wantBody: "console.log('hi')"},
+		{name: "precompressed variant", path: appURL, accept: "br, gzip", want: 200, wantCache: CacheImmutable, wantEnc: "br", wantBody: string(compressed("br", "console.log('hi')"))},
+		{name: "missing variant", path: s.URL("/assets/css/site.css"), accept: "gzip", want: 200, wantCache: CacheImmutable, wantBody: "body{}"},
+		{name: "variant not served directly", path: "/assets/app.js.br", want: 404},
+		{name: "stale fingerprint", path: "/assets/app.0000000000.js", want: 404},
+		{name: "no directory listing", path: "/assets/", want: 404},
+		{name: "no subdirectory listing", path: "/assets/css/", want: 404},
+		{name: "outside prefix", path: "/app.js", want: 404},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Accept-Encoding", tc.accept)
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status=%d want %d", rec.Code, tc.want)
+			}
+			if tc.want != 200 {
+				if ct := rec.Header().Get("Content-Type"); ct != ProblemContentType {
+					t.Fatalf("Content-Type=%q want problem details", ct)
+				}
+				return
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tc.wantCache {
+				t.Fatalf("Cache-Control=%q want %q", got, tc.wantCache)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tc.wantEnc {
+				t.Fatalf("Content-Encoding=%q want %q", got, tc.wantEnc)
+			}
+			if rec.Body.String() != tc.wantBody {
+				t.Fatalf("body=%q want %q", rec.Body.String(), tc.wantBody)
+			}
+			if rec.Header().Get("ETag") == "" {
+				t.Fatalf("missing ETag")
+			}
+		})
+	}
+}
+
+func TestStaticFilesConditional(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":    {Data: []byte("v1")},
+		"app.js.gz": {Data: compressed("gzip", "v1")},
+	}
+	s, err := NewStaticFiles(fsys, "/assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/assets/app.js", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	plain, gz := get("", "").Header().Get("ETag"), get("gzip", "").Header().Get("ETag")
+	if plain == gz {
+		t.Fatalf("identity and gzip share ETag %s", plain)
+	}
+	if rec := get("", plain); rec.Code != http.StatusNotModified {
+		t.Fatalf("matching ETag: status=%d want 304", rec.Code)
+	}
+	if rec := get("gzip", plain); rec.Code != http.StatusOK {
+		t.Fatalf("identity ETag must not validate the gzip variant: status=%d", rec.Code)
+	}
+
+	// The tag depends only on content, so a rebuild with equal bytes keeps it.
+	again, _ := NewStaticFiles(fstest.MapFS{"app.js": {Data: []byte("v1")}}, "/assets/")
+	req := httptest.NewRequest(http.MethodGet, "/assets/app.js", nil)
+	rec := httptest.NewRecorder()
+	again.ServeHTTP(rec, req)
+	if rec.Header().Get("ETag") != plain {
+		t.Fatalf("ETag changed across loads: %s vs %s", rec.Header().Get("ETag"), plain)
+	}
+}
+
+func TestStaticFilesRejectsStaleVariant(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"stale gzip":   {"app.js": {Data: []byte("v2")}, "app.js.gz": {Data: compressed("gzip", "v1")}},
+		"stale brotli": {"app.js": {Data: []byte("v2")}, "app.js.br": {Data: compressed("br", "v1")}},
+		"corrupt gzip": {"app.js": {Data: []byte("v2")}, "app.js.gz": {Data: []byte("not gzip")}},
+	}
+	for name, fsys := range cases {
+		if _, err := NewStaticFiles(fsys, "/assets/"); !errors.Is(err, ErrStaleVariant) {
+			t.Fatalf("%s: err=%v want ErrStaleVariant", name, err)
+		}
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	index := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("index"))
+	})
+	rt := NewRouter(Route{Method: http.MethodPost, Path: "/chat", Handler: index})
+	rt.NotFound = SPAFallback(index)
+	cases := []struct {
+		name, method, path, accept string
+		want                       int
+	}{
+		{"client route", http.MethodGet, "/conversations/42", "text/html,application/xhtml+xml", 200},
+		{"head", http.MethodHead, "/settings", "text/html", 200},
+		{"api client", http.MethodGet, "/conversations/42", "application/json", 404},
+		{"missing file", http.MethodGet, "/favicon.ico", "text/html", 404},
+		{"post", http.MethodPost, "/missing", "text/html", 404},
+		{"known path wrong method", http.MethodGet, "/chat", "text/html", 405},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("Accept", tc.accept)
+			rec := httptest.NewRecorder()
+			rt.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status=%d want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
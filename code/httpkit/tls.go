@@ -0,0 +1,231 @@
+package httpkit
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOptions enables HTTPS. The zero value serves plain HTTP.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS: client certificates are verified
+	// against this PEM bundle and exposed through Client.
+	ClientCAFile string
+	// RequireClientCert rejects handshakes without a valid client certificate.
+	// Otherwise certificates are verified when presented, so browsers without
+	// one still connect.
+	RequireClientCert bool
+	// ReloadInterval is how often the cert and key files are checked for
+	// changes; default 10s.
+	ReloadInterval time.Duration
+	// OnReload is called after every reload attempt; err is nil on success.
+	OnReload func(err error)
+}
+
+// TLSOptionsFromEnv reads TLS_CERT_FILE and TLS_KEY_FILE to serve HTTPS, plus
+// TLS_CLIENT_CA_FILE (and TLS_REQUIRE_CLIENT_CERT=1) for mutual TLS.
+// Certificate reloads are logged to logger. See cmd/devca for development
+// certificates.
+func TLSOptionsFromEnv(logger *slog.Logger) TLSOptions {
+	return TLSOptions{
+		CertFile:          os.Getenv("TLS_CERT_FILE"),
+		KeyFile:           os.Getenv("TLS_KEY_FILE"),
+		ClientCAFile:      os.Getenv("TLS_CLIENT_CA_FILE"),
+		RequireClientCert: os.Getenv("TLS_REQUIRE_CLIENT_CERT") == "1",
+		OnReload: func(err error) {
+			if err != nil {
+				logger.Error("certificate reload failed", "err", err)
+				return
+			}
+			logger.Info("certificate reloaded")
+		},
+	}
+}
+
+// Enabled reports whether a certificate is configured.
+func (o TLSOptions) Enabled() bool { return o.CertFile != "" }
+
+// NewTLSConfig returns a server config with TLS 1.2+, AEAD cipher suites,
+// modern curves and a certificate that reloads when its files change.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	reloader, err := NewCertReloader(opts.CertFile, opts.KeyFile, opts.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+	reloader.onReload = opts.OnReload
+	cfg := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		// Only used for TLS 1.2; TLS 1.3 suites are not configurable.
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		GetCertificate: reloader.GetCertificate,
+	}
+	if opts.ClientCAFile != "" {
+		pemBytes, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("client CA %s: no certificates found", opts.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if opts.RequireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return cfg, nil
+}
+
+// ListenAndServe serves srv over HTTPS when opts is enabled and plain HTTP
+// otherwise. Like http.Server it returns http.ErrServerClosed after Shutdown.
+func ListenAndServe(srv *http.Server, opts TLSOptions) error {
+	if !opts.Enabled() {
+		return srv.ListenAndServe()
+	}
+	cfg, err := NewTLSConfig(opts)
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = cfg
+	return srv.ListenAndServeTLS("", "")
+}
+
+// CertReloader serves a key pair from disk and reloads it when either file's
+// modification time changes, so rotated certificates apply without a restart.
+// Files are checked at most once per interval, during handshakes.
+type CertReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+	onReload          func(error)
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// NewCertReloader loads the key pair once and fails if it is invalid.
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	c := &CertReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. A failed reload keeps
+// serving the previous certificate.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.lastCheck) >= c.interval {
+		c.lastCheck = time.Now()
+		if c.changed() {
+			err := c.reload()
+			if c.onReload != nil {
+				c.onReload(err)
+			}
+		}
+	}
+	return c.cert, nil
+}
+
+func (c *CertReloader) changed() bool {
+	certInfo, err1 := os.Stat(c.certFile)
+	keyInfo, err2 := os.Stat(c.keyFile)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(c.certMod) || !keyInfo.ModTime().Equal(c.keyMod)
+}
+
+// reload must be called with c.mu held (or before c is shared).
+func (c *CertReloader) reload() error {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return fmt.Errorf("stat cert: %w", err)
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return fmt.Errorf("stat key: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	c.cert = &cert
+	c.certMod, c.keyMod = certInfo.ModTime(), keyInfo.ModTime()
+	c.lastCheck = time.Now()
+	return nil
+}
+
+// ClientIdentity describes a verified mTLS client certificate.
+type ClientIdentity struct {
+	CommonName   string
+	DNSNames     []string
+	URIs         []string // e.g. SPIFFE IDs
+	SerialNumber string
+}
+
+type clientIdentityKey struct{}
+
+// Client returns the mTLS client identity stored by ClientCertMiddleware.
+func Client(ctx context.Context) (ClientIdentity, bool) {
+	id, ok := ctx.Value(clientIdentityKey{}).(ClientIdentity)
+	return id, ok
+}
+
+// ClientCertMiddleware stores the verified client certificate's identity in
+// the context and adds it to the access log as "client". Connections without
+// TLS or without a client certificate pass through unchanged.
+func ClientCertMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := clientIdentity(r.TLS)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			AddLogAttrs(r.Context(), slog.String("client", id.CommonName))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, id)))
+		})
+	}
+}
+
+func clientIdentity(state *tls.ConnectionState) (ClientIdentity, bool) {
+	// VerifiedChains is only set when the chain verified against ClientCAs.
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ClientIdentity{}, false
+	}
+	leaf := state.VerifiedChains[0][0]
+	id := ClientIdentity{
+		CommonName:   leaf.Subject.CommonName,
+		DNSNames:     leaf.DNSNames,
+		SerialNumber: leaf.SerialNumber.String(),
+	}
+	for _, u := range leaf.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id, true
+}
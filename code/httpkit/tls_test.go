@@ -0,0 +1,214 @@
+package httpkit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeServerCert issues a localhost certificate from ca into dir.
+func writeServerCert(t *testing.T, ca *DevCA, dir string) (certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM, err := ca.IssueServer([]string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatalf("issue server: %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// newTLSServer serves h like ListenAndServe does and returns its base URL.
+// httptest.Server is not used because its own certificate would win over
+// GetCertificate.
+func newTLSServer(t *testing.T, opts TLSOptions, h http.Handler) string {
+	t.Helper()
+	cfg, err := NewTLSConfig(opts)
+	if err != nil {
+		t.Fatalf("tls config: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: h, TLSConfig: cfg, ErrorLog: log.New(io.Discard, "", 0)}
+	go func() { _ = srv.ServeTLS(ln, "", "") }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+func clientFor(ca *DevCA, certs ...tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: certs,
+	}}}
+}
+
+func TestCertReloader(t *testing.T) {
+	ca, err := NewDevCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatalf("ca: %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCert(t, ca, dir)
+	reloaded := make(chan error, 1)
+	srv := newTLSServer(t, TLSOptions{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: time.Nanosecond,
+		OnReload:       func(err error) { reloaded <- err },
+	}, http.NotFoundHandler())
+
+	serial := func() string {
+		resp, err := clientFor(ca).Get(srv)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.String()
+	}
+	before := serial()
+
+	writeServerCert(t, ca, dir)
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := serial(); after == before {
+		t.Fatalf("certificate not reloaded: serial %s", after)
+	}
+	if err := <-reloaded; err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca, err := NewDevCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatalf("ca: %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCert(t, ca, dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, ca.CertPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := ca.IssueClient("billing-service", time.Hour)
+	if err != nil {
+		t.Fatalf("issue client: %v", err)
+	}
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := NewDevCA("other CA", time.Hour)
+	otherPEM, otherKey, _ := other.IssueClient("intruder", time.Hour)
+	foreignCert, _ := tls.X509KeyPair(otherPEM, otherKey)
+
+	h := ClientCertMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := Client(r.Context())
+		_, _ = io.WriteString(w, id.CommonName)
+	}))
+
+	t.Run("optional", func(t *testing.T) {
+		srv := newTLSServer(t, TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, h)
+		for _, tc := range []struct {
+			name  string
+			certs []tls.Certificate
+			want  string
+		}{
+			{"with cert", []tls.Certificate{clientCert}, "billing-service"},
+			{"without cert", nil, ""},
+		} {
+			resp, err := clientFor(ca, tc.certs...).Get(srv)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != tc.want {
+				t.Fatalf("%s: identity=%q want %q", tc.name, body, tc.want)
+			}
+		}
+		// Go clients skip certificates the server's CA list does not accept,
+		// so force the foreign one to check that the server verifies it.
+		intruder := clientFor(ca)
+		intruder.Transport.(*http.Transport).TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &foreignCert, nil
+		}
+		if _, err := intruder.Get(srv); err == nil {
+			t.Fatalf("certificate from another CA must be rejected")
+		}
+	})
+
+	t.Run("required", func(t *testing.T) {
+		srv := newTLSServer(t, TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, RequireClientCert: true}, h)
+		if _, err := clientFor(ca).Get(srv); err == nil {
+			t.Fatalf("request without client certificate must fail")
+		}
+		resp, err := clientFor(ca, clientCert).Get(srv)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.TLS.Version < tls.VersionTLS12 {
+			t.Fatalf("negotiated TLS version %x", resp.TLS.Version)
+		}
+	})
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	if _, err := NewTLSConfig(TLSOptions{CertFile: "missing.pem", KeyFile: "missing-key.pem"}); err == nil {
+		t.Fatalf("missing files should fail")
+	}
+	ca, _ := NewDevCA("test CA", time.Hour)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCert(t, ca, dir)
+	bad := filepath.Join(dir, "bad.pem")
+	_ = os.WriteFile(bad, []byte("not pem"), 0o600)
+	if _, err := NewTLSConfig(TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: bad}); err == nil {
+		t.Fatalf("empty CA bundle should fail")
+	}
+}
+
+func TestTLSOptionsFromEnv(t *testing.T) {
+	t.Setenv("TLS_CERT_FILE", "server.pem")
+	t.Setenv("TLS_KEY_FILE", "server-key.pem")
+	t.Setenv("TLS_CLIENT_CA_FILE", "ca.pem")
+	t.Setenv("TLS_REQUIRE_CLIENT_CERT", "1")
+	var buf bytes.Buffer
+	opts := TLSOptionsFromEnv(slog.New(slog.NewTextHandler(&buf, nil)))
+	if opts.CertFile != "server.pem" || opts.KeyFile != "server-key.pem" || opts.ClientCAFile != "ca.pem" || !opts.RequireClientCert {
+		t.Fatalf("opts=%+v", opts)
+	}
+	opts.OnReload(nil)
+	opts.OnReload(errors.New("bad key"))
+	if !strings.Contains(buf.String(), "certificate reloaded") || !strings.Contains(buf.String(), "bad key") {
+		t.Fatalf("reloads not logged: %q", buf.String())
+	}
+
+	t.Setenv("TLS_CERT_FILE", "")
+	if TLSOptionsFromEnv(slog.Default()).Enabled() {
+		t.Fatalf("TLS enabled without TLS_CERT_FILE")
+	}
+}
@@ -0,0 +1,243 @@
+// Package validate checks structs against `validate:"..."` field tags and
+// reports every failing field at once.
+//
+// Rules are comma-separated:
+//
+//	required        value must not be zero (strings must not be blank)
+//	min=N, max=N    length for strings (in runes), slices and maps; value for numbers
+//	email           RFC 5322-lite address: local@domain.tld
+//	oneof=a b c     value must be one of the space-separated words
+//	regex=PATTERN   value must match; must be the last rule since PATTERN may contain commas
+//
+// Rules other than required skip zero values, so optional fields only need to
+// be valid when present. Nested structs, pointers to structs and slices of
+// structs are checked recursively. Field names come from the json tag.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError is one failed rule, the same shape as chapter 09's
+// ValidationError{Field, Reason}. Field is a JSON path such as "items[0].name".
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e FieldError) Error() string { return e.Field + ": " + e.Reason }
+
+// Errors lists every FieldError found by Struct, in field order.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Error()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Struct validates v, a struct or pointer to struct. It returns nil or Errors.
+// Malformed tags are programming errors and panic.
+func Struct(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: Struct called with %s", rv.Type()))
+	}
+	var errs Errors
+	checkStruct(rv, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func checkStruct(rv reflect.Value, prefix string, errs *Errors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := fieldName(sf)
+		if name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		fv := rv.Field(i)
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			if reason := checkValue(fv, tag); reason != "" {
+				*errs = append(*errs, FieldError{Field: path, Reason: reason})
+				continue
+			}
+		}
+		descend(fv, path, errs)
+	}
+}
+
+// descend validates nested structs reachable from fv.
+func descend(fv reflect.Value, path string, errs *Errors) {
+	switch fv.Kind() {
+	case reflect.Pointer:
+		if !fv.IsNil() {
+			descend(fv.Elem(), path, errs)
+		}
+	case reflect.Struct:
+		checkStruct(fv, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			descend(fv.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func fieldName(sf reflect.StructField) string {
+	if tag, ok := sf.Tag.Lookup("json"); ok {
+		if name, _, _ := strings.Cut(tag, ","); name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// checkValue returns the reason for the first failing rule, or "".
+func checkValue(fv reflect.Value, tag string) string {
+	zero := isZero(fv)
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if zero {
+				return "is required"
+			}
+			continue
+		}
+		if zero {
+			continue
+		}
+		if reason := applyRule(fv, name, arg); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func applyRule(fv reflect.Value, name, arg string) string {
+	for fv.Kind() == reflect.Pointer {
+		fv = fv.Elem()
+	}
+	switch name {
+	case "min", "max":
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad %s=%q", name, arg))
+		}
+		size, unit, ok := measure(fv)
+		if !ok {
+			panic(fmt.Sprintf("validate: %s does not apply to %s", name, fv.Type()))
+		}
+		if name == "min" && size < n {
+			return strings.TrimSpace("must be at least " + arg + " " + unit)
+		}
+		if name == "max" && size > n {
+			return strings.TrimSpace("must be at most " + arg + " " + unit)
+		}
+	case "email":
+		if !validEmail(stringOf(fv, name)) {
+			return "must be a valid email address"
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		s := fmt.Sprint(fv.Interface())
+		for _, o := range options {
+			if s == o {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", ")
+	case "regex":
+		if !compiled(arg).MatchString(stringOf(fv, name)) {
+			return "has an invalid format"
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", name))
+	}
+	return ""
+}
+
+// measure returns the size min/max compare against and its unit.
+func measure(fv reflect.Value) (float64, string, bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), "characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fv.Len()), "items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), "", true
+	}
+	return 0, "", false
+}
+
+func stringOf(fv reflect.Value, rule string) string {
+	if fv.Kind() != reflect.String {
+		panic(fmt.Sprintf("validate: %s needs a string, got %s", rule, fv.Type()))
+	}
+	return fv.String()
+}
+
+func isZero(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(fv.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	}
+	return fv.IsZero()
+}
+
+var regexCache sync.Map // pattern -> *regexp.Regexp
+
+func compiled(pattern string) *regexp.Regexp {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	regexCache.Store(pattern, re)
+	return re
+}
+
+// emailPattern is the RFC 5322 dot-atom local part without quoted strings or
+// comments, and a hostname with at least one dot and an alphabetic TLD.
+var emailPattern = regexp.MustCompile(
+	"^[A-Za-z0-9!#$%&'*+/=?^_`{|}~-]+(\\.[A-Za-z0-9!#$%&'*+/=?^_`{|}~-]+)*" +
+		`@([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)+[A-Za-z]{2,63}$`)
+
+func validEmail(s string) bool {
+	local, _, ok := strings.Cut(s, "@")
+	return ok && len(s) <= 254 && len(local) <= 64 && emailPattern.MatchString(s)
+}
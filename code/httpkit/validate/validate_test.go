@@ -0,0 +1,121 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"regex=^[0-9]{5}$"`
+}
+
+type signup struct {
+	Name    string    `json:"name" validate:"required,min=2,max=5"`
+	Email   string    `json:"email,omitempty" validate:"email"`
+	Plan    string    `json:"plan" validate:"required,oneof=free pro"`
+	Age     int       `json:"age" validate:"min=18,max=130"`
+	Tags    []string  `json:"tags" validate:"max=2"`
+	Home    address   `json:"home"`
+	Work    *address  `json:"work,omitempty"`
+	Friends []address `json:"friends"`
+	secret  string    `validate:"required"`
+}
+
+func TestStruct(t *testing.T) {
+	valid := signup{Name: "Ann", Plan: "pro", Home: address{City: "Oslo"}}
+	cases := []struct {
+		name   string
+		mutate func(*signup)
+		want   Errors
+	}{
+		{"valid", func(*signup) {}, nil},
+		{"optional fields present and valid", func(s *signup) {
+			s.Email, s.Age, s.Home.Zip = "ann.lee+go@mail.example.com", 30, "12345"
+		}, nil},
+		{"required blank", func(s *signup) { s.Name = "   " }, Errors{{"name", "is required"}}},
+		{"min length counts runes", func(s *signup) { s.Name = "é" }, Errors{{"name", "must be at least 2 characters"}}},
+		{"max length", func(s *signup) { s.Name = "Annabel" }, Errors{{"name", "must be at most 5 characters"}}},
+		{"enum", func(s *signup) { s.Plan = "gold" }, Errors{{"plan", "must be one of: free, pro"}}},
+		{"number range", func(s *signup) { s.Age = 12 }, Errors{{"age", "must be at least 18"}}},
+		{"slice length", func(s *signup) { s.Tags = []string{"a", "b", "c"} }, Errors{{"tags", "must be at most 2 items"}}},
+		{"nested struct", func(s *signup) { s.Home = address{Zip: "12"} }, Errors{
+			{"home.city", "is required"},
+			{"home.zip", "has an invalid format"},
+		}},
+		{"nested pointer and slice", func(s *signup) {
+			s.Work = &address{}
+			s.Friends = []address{{City: "Rome"}, {}}
+		}, Errors{{"work.city", "is required"}, {"friends[1].city", "is required"}}},
+		{"all errors at once", func(s *signup) { *s = signup{Email: "nope"} }, Errors{
+			{"name", "is required"},
+			{"email", "must be a valid email address"},
+			{"plan", "is required"},
+			{"home.city", "is required"},
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := valid
+			tc.mutate(&s)
+			err := Struct(&s)
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("err=%v want nil", err)
+				}
+				return
+			}
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("err=%v (%T) want Errors", err, err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("errors=%v want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("errors[%d]=%+v want %+v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEmail(t *testing.T) {
+	for _, ok := range []string{"a@b.co", "first.last@sub.example.org", "x+tag@example.io", "o'neil@example.ie"} {
+		if !validEmail(ok) {
+			t.Fatalf("%q should be valid", ok)
+		}
+	}
+	for _, bad := range []string{"", "plain", "a@b", "@example.com", "a@.com", "a..b@example.com", ".a@example.com",
+		"a@example..com", "a@-example.com", "a b@example.com", "Ann <a@example.com>", "a@example.c0m"} {
+		if validEmail(bad) {
+			t.Fatalf("%q should be invalid", bad)
+		}
+	}
+}
+
+func TestErrorsJSON(t *testing.T) {
+	err := Struct(struct {
+		Message string `json:"message" validate:"required"`
+	}{})
+	out, _ := json.Marshal(err)
+	if string(out) != `[{"field":"message","reason":"is required"}]` {
+		t.Fatalf("json=%s", out)
+	}
+	if err.Error() != "message: is required" {
+		t.Fatalf("Error()=%q", err.Error())
+	}
+}
+
+func TestBadTagPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("unknown rule should panic")
+		}
+	}()
+	_ = Struct(struct {
+		A string `validate:"shiny"`
+	}{A: "x"})
+}